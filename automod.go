@@ -0,0 +1,121 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+// Messages AutoMod held in a tab, hidden while there are none. Only moderators get them.
+type autoModPane struct {
+	box   *fyne.Container
+	title *widget.Label
+	list  *widget.List
+
+	// Changed by eventsub notifications and resolve results in the background, read by the list
+	heldMutex sync.Mutex
+	held      []*hasherino.AutoModMessageEvent
+
+	window fyne.Window // where errors are shown, only touched by the UI
+}
+
+func newAutoModPane(hc *hasherino.HasherinoController, channel string, window fyne.Window) *autoModPane {
+	p := &autoModPane{window: window}
+	p.title = widget.NewLabelWithStyle("AutoMod", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	resolve := func(message *hasherino.AutoModMessageEvent, allow bool) {
+		// Read here rather than in the goroutine since popping the chat out changes it
+		window := p.window
+		go func() {
+			err := hc.ResolveAutoModMessage(channel, message.MessageId, allow)
+			fyne.Do(func() {
+				if err != nil {
+					dialog.ShowError(err, window)
+					return
+				}
+				p.remove(message.MessageId)
+			})
+		}()
+	}
+	p.list = widget.NewList(
+		func() int {
+			p.heldMutex.Lock()
+			defer p.heldMutex.Unlock()
+			return len(p.held)
+		},
+		func() fyne.CanvasObject {
+			text := widget.NewLabel("template")
+			text.Wrapping = fyne.TextWrapWord
+			reason := widget.NewLabelWithStyle("template", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+			buttons := container.NewHBox(widget.NewButton("Approve", nil), widget.NewButton("Deny", nil))
+			return container.NewBorder(nil, nil, nil, buttons, container.NewVBox(text, reason))
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			p.heldMutex.Lock()
+			if i >= len(p.held) {
+				p.heldMutex.Unlock()
+				return
+			}
+			message := p.held[i]
+			p.heldMutex.Unlock()
+			row := o.(*fyne.Container)
+			labels := row.Objects[0].(*fyne.Container).Objects
+			labels[0].(*widget.Label).SetText(message.UserName + ": " + message.Message.Text)
+			labels[1].(*widget.Label).SetText(message.HeldAt.Local().Format("15:04") + " " +
+				message.Category + ", level " + strconv.Itoa(message.Level))
+			buttons := row.Objects[1].(*fyne.Container).Objects
+			buttons[0].(*widget.Button).OnTapped = func() {
+				resolve(message, true)
+			}
+			buttons[1].(*widget.Button).OnTapped = func() {
+				resolve(message, false)
+			}
+		})
+	scroll := container.NewVScroll(p.list)
+	scroll.SetMinSize(fyne.NewSize(0, 150))
+
+	p.box = container.NewBorder(p.title, nil, nil, nil, scroll)
+	p.box.Hide()
+	return p
+}
+
+// Adds held messages and drops the ones another moderator or the timeout resolved
+func (p *autoModPane) Update(message *hasherino.AutoModMessageEvent) {
+	if message.Resolved() {
+		p.remove(message.MessageId)
+		return
+	}
+	p.heldMutex.Lock()
+	p.held = append(p.held, message)
+	p.heldMutex.Unlock()
+	p.refresh()
+}
+
+func (p *autoModPane) remove(messageId string) {
+	p.heldMutex.Lock()
+	p.held = slices.DeleteFunc(p.held, func(held *hasherino.AutoModMessageEvent) bool {
+		return held.MessageId == messageId
+	})
+	p.heldMutex.Unlock()
+	p.refresh()
+}
+
+func (p *autoModPane) refresh() {
+	p.heldMutex.Lock()
+	count := len(p.held)
+	p.heldMutex.Unlock()
+	p.title.SetText("AutoMod (" + strconv.Itoa(count) + " held)")
+	if count == 0 {
+		p.box.Hide()
+	} else {
+		p.box.Show()
+	}
+	p.list.Refresh()
+}
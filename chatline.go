@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"regexp"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Hashy-Software/hasherino-go/components"
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+// Translucent background for a #rrggbb highlight color, a default one if it can't be parsed
+func highlightColor(hex string) color.Color {
+	c := color.NRGBA{R: 0x7f, G: 0x3f, B: 0x49, A: 0x80}
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(hex, "#%02x%02x%02x", &r, &g, &b); err == nil {
+		c.R, c.G, c.B = r, g, b
+	}
+	return c
+}
+
+// Recent lines searched for the other half of a redemption, its message or its event
+const redemptionMatchLines = 50
+
+// Line for a redemption that has no chat message yet, its user input stands in for the text
+func newRedemptionLine(channel string, redemption *hasherino.RedemptionEvent) *hasherino.StoredMessage {
+	return &hasherino.StoredMessage{
+		Message: hasherino.ChatMessage{
+			Channel: channel,
+			Command: "PRIVMSG",
+			Author:  redemption.UserLogin,
+			Text:    redemption.UserInput,
+			Tags: map[string]string{
+				"display-name":     redemption.UserName,
+				"custom-reward-id": redemption.Reward.Id,
+			},
+		},
+		Redemption: redemption,
+	}
+}
+
+// Whether message is the chat message sent along with redemption
+func matchesRedemption(message hasherino.ChatMessage, redemption *hasherino.RedemptionEvent) bool {
+	return message.Tags["custom-reward-id"] == redemption.Reward.Id &&
+		message.Author == redemption.UserLogin &&
+		message.Text == redemption.UserInput
+}
+
+// Splits text into segments of colorName, emphasizing the parts that match
+func textSegments(text string, match *regexp.Regexp, colorName fyne.ThemeColorName) []widget.RichTextSegment {
+	plain := func(text string) widget.RichTextSegment {
+		return &widget.TextSegment{Text: text, Style: widget.RichTextStyle{Inline: true, ColorName: colorName, SizeName: theme.SizeNameText}}
+	}
+	if match == nil {
+		return []widget.RichTextSegment{plain(text)}
+	}
+	segments := []widget.RichTextSegment{}
+	last := 0
+	for _, loc := range match.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		if loc[0] > last {
+			segments = append(segments, plain(text[last:loc[0]]))
+		}
+		segments = append(segments, &widget.TextSegment{Text: text[loc[0]:loc[1]], Style: widget.RichTextStyle{
+			Inline:    true,
+			ColorName: theme.ColorNamePrimary,
+			SizeName:  theme.SizeNameText,
+			TextStyle: fyne.TextStyle{Bold: true},
+		}})
+		last = loc[1]
+	}
+	if last < len(text) || len(segments) == 0 {
+		segments = append(segments, plain(text[last:]))
+	}
+	return segments
+}
+
+// Italic text of lines that aren't plain chat, like deleted messages
+func noteSegments(text string, colorName fyne.ThemeColorName) []widget.RichTextSegment {
+	return []widget.RichTextSegment{&widget.TextSegment{
+		Text: text,
+		Style: widget.RichTextStyle{
+			Inline:    true,
+			ColorName: colorName,
+			SizeName:  theme.SizeNameText,
+			TextStyle: fyne.TextStyle{Italic: true},
+		},
+	}}
+}
+
+// Row of a message list, reused as the list scrolls. Callbacks left nil hide their action.
+type chatLineRow struct {
+	widget.BaseWidget
+
+	replyContext *widget.Hyperlink
+	redemption   *widget.Label
+	badges       *fyne.Container
+	author       *widget.Hyperlink
+	text         *widget.RichText
+	replyButton  *widget.Button
+	background   *canvas.Rectangle
+	root         fyne.CanvasObject
+
+	onUserTapped func(login string)
+	onReply      func(hasherino.ChatMessage)
+	onThread     func(threadId string)
+}
+
+func newChatLineRow(
+	onUserTapped func(login string),
+	onReply func(hasherino.ChatMessage),
+	onThread func(threadId string),
+) *chatLineRow {
+	r := &chatLineRow{
+		onUserTapped: onUserTapped,
+		onReply:      onReply,
+		onThread:     onThread,
+	}
+	r.replyContext = widget.NewHyperlink("", nil)
+	r.replyContext.Hide()
+	r.redemption = widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+	r.redemption.Hide()
+	r.badges = container.NewHBox()
+	r.author = widget.NewHyperlink("template", nil)
+	r.text = widget.NewRichTextWithText("template")
+	r.text.Wrapping = fyne.TextWrapWord
+	r.replyButton = widget.NewButton("↩", nil)
+	r.replyButton.Importance = widget.LowImportance
+	r.replyButton.Hide()
+
+	r.background = canvas.NewRectangle(color.Transparent)
+	r.root = container.NewStack(r.background, container.NewVBox(
+		r.replyContext,
+		r.redemption,
+		container.NewBorder(nil, nil, container.NewHBox(r.badges, r.author), r.replyButton, r.text),
+	))
+	r.ExtendBaseWidget(r)
+	return r
+}
+
+func (r *chatLineRow) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(r.root)
+}
+
+// Shows stored, emphasizing the parts of its text matching findMatch if it's set
+func (r *chatLineRow) SetLine(stored *hasherino.StoredMessage, findMatch *regexp.Regexp) {
+	message := stored.Message
+
+	if parentId := message.ReplyParentId(); parentId != "" {
+		parentName := message.Tags["reply-parent-display-name"]
+		if parentName == "" {
+			parentName = message.Tags["reply-parent-user-login"]
+		}
+		r.replyContext.SetText("Replying to @" + parentName + ": " + message.Tags["reply-parent-msg-body"])
+		r.replyContext.OnTapped = func() {
+			if r.onThread != nil {
+				r.onThread(message.ThreadId())
+			}
+		}
+		r.replyContext.Show()
+	} else {
+		r.replyContext.Hide()
+	}
+
+	r.background.FillColor = color.Transparent
+	if message.Highlight != nil {
+		r.background.FillColor = highlightColor(message.Highlight.Color)
+	}
+	r.background.Refresh()
+
+	if stored.Redemption != nil {
+		reward := stored.Redemption.Reward
+		r.redemption.SetText("Redeemed " + reward.Title + " (" + strconv.Itoa(reward.Cost) + ")")
+		r.redemption.Show()
+	} else {
+		r.redemption.Hide()
+	}
+
+	// Badge widgets are kept across lines, the ones this line doesn't need are hidden
+	for i, badge := range stored.Badges {
+		if i == len(r.badges.Objects) {
+			r.badges.Add(components.NewImageWidget(badge, defaultBadgeSize))
+		}
+		badgeWidget := r.badges.Objects[i].(*components.WebpWidget)
+		badgeWidget.SetImage(badge)
+		badgeWidget.LazyLoad()
+		badgeWidget.Show()
+	}
+	for _, badgeWidget := range r.badges.Objects[len(stored.Badges):] {
+		badgeWidget.Hide()
+	}
+	r.badges.Refresh()
+
+	if message.Command == hasherino.SystemCommand {
+		r.author.Hide()
+	} else {
+		r.author.SetText(message.AuthorName() + ":")
+		r.author.OnTapped = func() {
+			// Messages from old logs don't know their author's login
+			if r.onUserTapped != nil && message.Author != "" {
+				r.onUserTapped(message.Author)
+			}
+		}
+		r.author.Show()
+	}
+	switch {
+	case message.Command == hasherino.SystemCommand:
+		r.text.Segments = noteSegments(message.Text, theme.ColorNamePlaceHolder)
+	case stored.Deleted:
+		r.text.Segments = noteSegments(message.ReplyText()+" (deleted)", theme.ColorNameDisabled)
+	default:
+		// History is dimmed to tell it apart from what was sent since joining
+		colorName := theme.ColorNameForeground
+		if message.Historical() {
+			colorName = theme.ColorNamePlaceHolder
+		}
+		r.text.Segments = textSegments(message.ReplyText(), findMatch, colorName)
+	}
+	r.text.Refresh()
+
+	if r.onReply != nil && message.Id() != "" {
+		r.replyButton.OnTapped = func() {
+			r.onReply(message)
+		}
+		r.replyButton.Show()
+	} else {
+		r.replyButton.Hide()
+	}
+}
+
+// Shows every message of a reply thread that's still in the tab, with an entry to reply to its
+// first message. Returns a function that refreshes it when a message of the thread arrives.
+func ShowThreadWindow(
+	hc *hasherino.HasherinoController,
+	channel string,
+	threadId string,
+	getLines func() []*hasherino.StoredMessage,
+	showUserCard func(login string),
+	onClosed func(),
+) func() {
+	newWindow := fyne.CurrentApp().NewWindow("Thread - " + channel)
+	newWindow.Resize(fyne.NewSize(400, 500))
+
+	var lines []*hasherino.StoredMessage
+	list := widget.NewList(
+		func() int {
+			return len(lines)
+		},
+		func() fyne.CanvasObject {
+			return newChatLineRow(showUserCard, nil, nil)
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			o.(*chatLineRow).SetLine(lines[i], nil)
+		})
+	refresh := func() {
+		lines = []*hasherino.StoredMessage{}
+		for _, line := range getLines() {
+			if line.Message.Id() == threadId || line.Message.ThreadId() == threadId {
+				lines = append(lines, line)
+			}
+		}
+		list.Refresh()
+		list.ScrollToBottom()
+	}
+
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder("Reply to thread")
+	entry.OnSubmitted = func(text string) {
+		if text == "" {
+			return
+		}
+		if err := hc.SendReply(channel, text, threadId); err != nil {
+			dialog.ShowError(err, newWindow)
+			return
+		}
+		entry.SetText("")
+	}
+
+	newWindow.SetContent(windowContent(container.NewBorder(nil, entry, nil, nil, list)))
+	newWindow.SetOnClosed(onClosed)
+	refresh()
+	newWindow.Show()
+	newWindow.Canvas().Focus(entry)
+	return refresh
+}
@@ -0,0 +1,232 @@
+package components
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"time"
+
+	"golang.org/x/image/webp"
+)
+
+var errInvalidAnimatedWebp = errors.New("webp: invalid animated webp")
+
+const (
+	webpAnimationFlag = 1 << 1 // VP8X flag set on animated files
+	webpAlphaFlag     = 1 << 4 // VP8X flag set when an ALPH chunk is present
+	anmfDisposeFlag   = 1 << 0 // dispose the frame rect to background before the next frame
+	anmfNoBlendFlag   = 1 << 1 // replace the frame rect instead of alpha blending
+)
+
+// AnimatedWebp holds every frame of an animated webp, already composited onto the canvas,
+// so each frame can be shown as is.
+type AnimatedWebp struct {
+	Config     image.Config
+	Background color.NRGBA // suggested by the file, most decoders draw on transparent instead
+	LoopCount  int         // 0 loops forever
+	Frames     []*image.NRGBA
+	Delay      []int // milliseconds
+}
+
+// Animation converts the decoded frames for playback on the animation clock
+func (a *AnimatedWebp) Animation() *Animation {
+	frames := make([]image.Image, len(a.Frames))
+	delay := make([]time.Duration, len(a.Delay))
+	for i, frame := range a.Frames {
+		frames[i] = frame
+		delay[i] = time.Duration(a.Delay[i]) * time.Millisecond
+	}
+	return NewAnimation(frames, delay, a.LoopCount)
+}
+
+type webpChunk struct {
+	id   string
+	data []byte
+}
+
+type webpFrame struct {
+	rect    image.Rectangle
+	delay   int
+	blend   bool
+	dispose bool
+	image   image.Image
+}
+
+// IsAnimatedWebp checks the VP8X header without decoding anything.
+func IsAnimatedWebp(content []byte) bool {
+	if len(content) < 21 || string(content[:4]) != "RIFF" || string(content[8:12]) != "WEBP" {
+		return false
+	}
+	return string(content[12:16]) == "VP8X" && content[20]&webpAnimationFlag != 0
+}
+
+// DecodeAnimatedWebp decodes every frame of an animated webp(ANIM and ANMF chunks),
+// applying each frame's offset, blending and disposal.
+func DecodeAnimatedWebp(r io.Reader) (*AnimatedWebp, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) < 12 || string(content[:4]) != "RIFF" || string(content[8:12]) != "WEBP" {
+		return nil, errInvalidAnimatedWebp
+	}
+	riffSize := int(binary.LittleEndian.Uint32(content[4:8]))
+	if riffSize+8 < len(content) {
+		content = content[:riffSize+8]
+	}
+	chunks, err := readWebpChunks(content[12:])
+	if err != nil {
+		return nil, err
+	}
+
+	anim := &AnimatedWebp{}
+	var canvas *image.NRGBA
+	var previous *webpFrame
+	for _, chunk := range chunks {
+		switch chunk.id {
+		case "VP8X":
+			if len(chunk.data) < 10 {
+				return nil, errInvalidAnimatedWebp
+			}
+			anim.Config = image.Config{
+				ColorModel: color.NRGBAModel,
+				Width:      uint24(chunk.data[4:]) + 1,
+				Height:     uint24(chunk.data[7:]) + 1,
+			}
+			canvas = image.NewNRGBA(image.Rect(0, 0, anim.Config.Width, anim.Config.Height))
+		case "ANIM":
+			if len(chunk.data) < 6 {
+				return nil, errInvalidAnimatedWebp
+			}
+			anim.Background = color.NRGBA{B: chunk.data[0], G: chunk.data[1], R: chunk.data[2], A: chunk.data[3]}
+			anim.LoopCount = int(binary.LittleEndian.Uint16(chunk.data[4:6]))
+		case "ANMF":
+			if canvas == nil {
+				return nil, errInvalidAnimatedWebp
+			}
+			frame, err := decodeWebpFrame(chunk.data)
+			if err != nil {
+				return nil, err
+			}
+			if previous != nil && previous.dispose {
+				draw.Draw(canvas, previous.rect, image.Transparent, image.Point{}, draw.Src)
+			}
+			op := draw.Over
+			if !frame.blend {
+				op = draw.Src
+			}
+			draw.Draw(canvas, frame.rect, frame.image, frame.image.Bounds().Min, op)
+
+			snapshot := image.NewNRGBA(canvas.Bounds())
+			copy(snapshot.Pix, canvas.Pix)
+			anim.Frames = append(anim.Frames, snapshot)
+			anim.Delay = append(anim.Delay, frame.delay)
+			previous = frame
+		}
+	}
+	if len(anim.Frames) == 0 {
+		return nil, errInvalidAnimatedWebp
+	}
+	return anim, nil
+}
+
+// decodeWebpFrame parses an ANMF payload. The frame's bitstream is wrapped in a
+// standalone webp file so it can be decoded by golang.org/x/image/webp.
+func decodeWebpFrame(data []byte) (*webpFrame, error) {
+	if len(data) < 16 {
+		return nil, errInvalidAnimatedWebp
+	}
+	x, y := uint24(data[0:])*2, uint24(data[3:])*2
+	width, height := uint24(data[6:])+1, uint24(data[9:])+1
+	delay := uint24(data[12:])
+	flags := data[15]
+
+	subchunks, err := readWebpChunks(data[16:])
+	if err != nil {
+		return nil, err
+	}
+	var alpha, bitstream *webpChunk
+	for i := range subchunks {
+		switch subchunks[i].id {
+		case "ALPH":
+			alpha = &subchunks[i]
+		case "VP8 ", "VP8L":
+			bitstream = &subchunks[i]
+		}
+	}
+	if bitstream == nil {
+		return nil, errInvalidAnimatedWebp
+	}
+
+	body := &bytes.Buffer{}
+	if alpha != nil && bitstream.id == "VP8 " {
+		vp8x := make([]byte, 10)
+		vp8x[0] = webpAlphaFlag
+		putUint24(vp8x[4:], width-1)
+		putUint24(vp8x[7:], height-1)
+		writeWebpChunk(body, "VP8X", vp8x)
+		writeWebpChunk(body, "ALPH", alpha.data)
+	}
+	writeWebpChunk(body, bitstream.id, bitstream.data)
+
+	file := &bytes.Buffer{}
+	file.WriteString("RIFF")
+	binary.Write(file, binary.LittleEndian, uint32(4+body.Len()))
+	file.WriteString("WEBP")
+	file.Write(body.Bytes())
+
+	img, err := webp.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+	return &webpFrame{
+		rect:    image.Rect(x, y, x+width, y+height),
+		delay:   delay,
+		blend:   flags&anmfNoBlendFlag == 0,
+		dispose: flags&anmfDisposeFlag != 0,
+		image:   img,
+	}, nil
+}
+
+func readWebpChunks(data []byte) ([]webpChunk, error) {
+	chunks := []webpChunk{}
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, errInvalidAnimatedWebp
+		}
+		id := string(data[:4])
+		size := binary.LittleEndian.Uint32(data[4:8])
+		data = data[8:]
+		if uint64(size) > uint64(len(data)) {
+			return nil, errInvalidAnimatedWebp
+		}
+		chunks = append(chunks, webpChunk{id: id, data: data[:size]})
+		data = data[size:]
+		// Chunks are padded to an even size
+		if size%2 == 1 && len(data) > 0 {
+			data = data[1:]
+		}
+	}
+	return chunks, nil
+}
+
+func writeWebpChunk(w *bytes.Buffer, id string, data []byte) {
+	w.WriteString(id)
+	binary.Write(w, binary.LittleEndian, uint32(len(data)))
+	w.Write(data)
+	if len(data)%2 == 1 {
+		w.WriteByte(0)
+	}
+}
+
+func uint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
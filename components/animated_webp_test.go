@@ -0,0 +1,134 @@
+package components_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"testing"
+
+	"github.com/Hashy-Software/hasherino-go/components"
+)
+
+type bitWriter struct {
+	buf   []byte
+	nBits uint
+}
+
+// write appends n bits of v, least significant bit first as VP8L expects
+func (w *bitWriter) write(v uint32, n uint) {
+	for i := uint(0); i < n; i++ {
+		if w.nBits%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		w.buf[len(w.buf)-1] |= byte((v>>i)&1) << (w.nBits % 8)
+		w.nBits++
+	}
+}
+
+// solidVP8L encodes a single color lossless image, using one symbol huffman codes so pixels take no bits
+func solidVP8L(width, height int, c color.NRGBA) []byte {
+	w := &bitWriter{}
+	w.write(0x2f, 8)
+	w.write(uint32(width-1), 14)
+	w.write(uint32(height-1), 14)
+	w.write(1, 1) // alpha is used
+	w.write(0, 3) // version
+	w.write(0, 1) // no transforms
+	w.write(0, 1) // no color cache
+	w.write(0, 1) // no meta prefix codes
+	for _, symbol := range []uint8{c.G, c.R, c.B, c.A, 0} {
+		w.write(1, 1) // simple code
+		w.write(0, 1) // one symbol
+		w.write(1, 1) // 8 bit symbol
+		w.write(uint32(symbol), 8)
+	}
+	return w.buf
+}
+
+func chunk(id string, data []byte) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString(id)
+	binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	if len(data)%2 == 1 {
+		buf.WriteByte(0)
+	}
+	return buf.Bytes()
+}
+
+func uint24(v int) []byte {
+	return []byte{byte(v), byte(v >> 8), byte(v >> 16)}
+}
+
+func anmf(x, y, width, height, delay int, flags byte, bitstream []byte) []byte {
+	data := []byte{}
+	data = append(data, uint24(x/2)...)
+	data = append(data, uint24(y/2)...)
+	data = append(data, uint24(width-1)...)
+	data = append(data, uint24(height-1)...)
+	data = append(data, uint24(delay)...)
+	data = append(data, flags)
+	data = append(data, chunk("VP8L", bitstream)...)
+	return chunk("ANMF", data)
+}
+
+func TestDecodeAnimatedWebp(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+	green := color.NRGBA{G: 255, A: 255}
+	transparent := color.NRGBA{}
+
+	vp8x := append([]byte{1<<4 | 1<<1, 0, 0, 0}, append(uint24(3), uint24(3)...)...)
+	body := []byte("WEBP")
+	body = append(body, chunk("VP8X", vp8x)...)
+	body = append(body, chunk("ANIM", []byte{0, 0, 0, 0, 2, 0})...)
+	// Full red frame, blended
+	body = append(body, anmf(0, 0, 4, 4, 100, 0, solidVP8L(4, 4, red))...)
+	// Blue square over the bottom right, disposed to background afterwards
+	body = append(body, anmf(2, 2, 2, 2, 50, 1, solidVP8L(2, 2, blue))...)
+	// Transparent square without blending punches a hole in the top left
+	body = append(body, anmf(0, 0, 2, 2, 50, 1<<1, solidVP8L(2, 2, transparent))...)
+	// Green square at the top right
+	body = append(body, anmf(2, 0, 2, 2, 70, 0, solidVP8L(2, 2, green))...)
+
+	file := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	file = append(file, body...)
+
+	if !components.IsAnimatedWebp(file) {
+		t.Fatal("expected file to be detected as animated")
+	}
+	anim, err := components.DecodeAnimatedWebp(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if anim.Config.Width != 4 || anim.Config.Height != 4 {
+		t.Errorf("unexpected canvas size %dx%d", anim.Config.Width, anim.Config.Height)
+	}
+	if anim.LoopCount != 2 {
+		t.Errorf("expected loop count 2, got %d", anim.LoopCount)
+	}
+	if len(anim.Frames) != 4 {
+		t.Fatalf("expected 4 frames, got %d", len(anim.Frames))
+	}
+	expectedDelay := []int{100, 50, 50, 70}
+	for i, delay := range expectedDelay {
+		if anim.Delay[i] != delay {
+			t.Errorf("frame %d: expected delay %d, got %d", i, delay, anim.Delay[i])
+		}
+	}
+
+	expected := []map[[2]int]color.NRGBA{
+		{{0, 0}: red, {3, 3}: red},
+		{{0, 0}: red, {2, 2}: blue, {3, 3}: blue},
+		{{0, 0}: transparent, {1, 1}: transparent, {2, 2}: transparent, {3, 2}: transparent, {0, 3}: red},
+		{{0, 0}: transparent, {3, 0}: green, {0, 3}: red, {3, 3}: transparent},
+	}
+	for i, pixels := range expected {
+		for point, c := range pixels {
+			got := anim.Frames[i].NRGBAAt(point[0], point[1])
+			if got != c {
+				t.Errorf("frame %d pixel %v: expected %v, got %v", i, point, c, got)
+			}
+		}
+	}
+}
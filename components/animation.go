@@ -0,0 +1,171 @@
+package components
+
+import (
+	"image"
+	"sort"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+const (
+	defaultAnimationInterval = 20 * time.Millisecond
+	minFrameDelay            = 20 * time.Millisecond
+	defaultFrameDelay        = 100 * time.Millisecond // used for frames faster than minFrameDelay, like browsers do
+)
+
+// Animation is a decoded animation with every frame fully composited.
+// It's created once per emote and shared by every widget showing it, so frames must not be modified.
+type Animation struct {
+	Frames    []image.Image
+	Delay     []time.Duration
+	LoopCount int // how many times the animation plays, 0 loops forever
+
+	ends     []time.Duration // time at which each frame ends, within one loop
+	duration time.Duration
+}
+
+func NewAnimation(frames []image.Image, delay []time.Duration, loopCount int) *Animation {
+	a := &Animation{
+		Frames:    frames,
+		Delay:     make([]time.Duration, len(frames)),
+		LoopCount: loopCount,
+		ends:      make([]time.Duration, len(frames)),
+	}
+	for i := range frames {
+		d := defaultFrameDelay
+		if i < len(delay) && delay[i] >= minFrameDelay {
+			d = delay[i]
+		}
+		a.Delay[i] = d
+		a.duration += d
+		a.ends[i] = a.duration
+	}
+	return a
+}
+
+// FrameAt returns the index of the frame shown after the animation played for elapsed.
+func (a *Animation) FrameAt(elapsed time.Duration) int {
+	if len(a.Frames) <= 1 || a.duration == 0 {
+		return 0
+	}
+	if a.LoopCount > 0 && elapsed >= a.duration*time.Duration(a.LoopCount) {
+		return len(a.Frames) - 1
+	}
+	elapsed %= a.duration
+	return sort.Search(len(a.ends), func(i int) bool {
+		return a.ends[i] > elapsed
+	})
+}
+
+// Finished reports if a limited animation reached its last frame for good.
+func (a *Animation) Finished(elapsed time.Duration) bool {
+	return len(a.Frames) <= 1 || (a.LoopCount > 0 && elapsed >= a.duration*time.Duration(a.LoopCount))
+}
+
+type animationSubscriber interface {
+	animationTick(now time.Time)
+}
+
+// AnimationClock drives every animated widget from a single ticker.
+// Looping animations pick their frame from the time since the clock's epoch,
+// so every instance of the same emote shows the same frame.
+type AnimationClock struct {
+	interval time.Duration
+	epoch    time.Time
+
+	mutex       sync.Mutex
+	subscribers map[animationSubscriber]struct{}
+	paused      bool
+	stop        chan struct{} // set while the ticker goroutine runs
+}
+
+func NewAnimationClock(interval time.Duration) *AnimationClock {
+	return &AnimationClock{
+		interval:    interval,
+		epoch:       time.Now(),
+		subscribers: make(map[animationSubscriber]struct{}),
+	}
+}
+
+var (
+	defaultAnimationClock     *AnimationClock
+	defaultAnimationClockOnce sync.Once
+)
+
+// Returns the clock shared by every animated emote
+func DefaultAnimationClock() *AnimationClock {
+	defaultAnimationClockOnce.Do(func() {
+		defaultAnimationClock = NewAnimationClock(defaultAnimationInterval)
+	})
+	return defaultAnimationClock
+}
+
+func (c *AnimationClock) Epoch() time.Time {
+	return c.epoch
+}
+
+func (c *AnimationClock) Subscribe(s animationSubscriber) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.subscribers[s] = struct{}{}
+	c.update()
+}
+
+func (c *AnimationClock) Unsubscribe(s animationSubscriber) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.subscribers, s)
+	c.update()
+}
+
+// SetPaused stops ticking without dropping subscribers, e.g. while the window isn't focused.
+func (c *AnimationClock) SetPaused(paused bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.paused = paused
+	c.update()
+}
+
+func (c *AnimationClock) IsPaused() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.paused
+}
+
+// Starts or stops the ticker goroutine. Must hold c.mutex.
+func (c *AnimationClock) update() {
+	shouldRun := len(c.subscribers) > 0 && !c.paused
+	if shouldRun && c.stop == nil {
+		c.stop = make(chan struct{})
+		go c.run(c.stop)
+	} else if !shouldRun && c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+}
+
+func (c *AnimationClock) run(stop chan struct{}) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			c.mutex.Lock()
+			subscribers := make([]animationSubscriber, 0, len(c.subscribers))
+			for s := range c.subscribers {
+				subscribers = append(subscribers, s)
+			}
+			c.mutex.Unlock()
+			// The frames are swapped where fyne draws them
+			fyne.Do(func() {
+				for _, s := range subscribers {
+					s.animationTick(now)
+				}
+			})
+		}
+	}
+}
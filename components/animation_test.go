@@ -0,0 +1,46 @@
+package components_test
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/Hashy-Software/hasherino-go/components"
+)
+
+func testFrames(n int) []image.Image {
+	frames := make([]image.Image, n)
+	for i := range frames {
+		frames[i] = image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	}
+	return frames
+}
+
+func TestAnimationFrameAt(t *testing.T) {
+	ms := time.Millisecond
+	// The 0 delay is raised to the default delay of 100ms
+	anim := components.NewAnimation(testFrames(3), []time.Duration{50 * ms, 0, 30 * ms}, 0)
+
+	cases := map[time.Duration]int{
+		0:        0,
+		49 * ms:  0,
+		50 * ms:  1,
+		149 * ms: 1,
+		150 * ms: 2,
+		180 * ms: 0, // looped
+		230 * ms: 1,
+	}
+	for elapsed, expected := range cases {
+		if frame := anim.FrameAt(elapsed); frame != expected {
+			t.Errorf("at %s: expected frame %d, got %d", elapsed, expected, frame)
+		}
+	}
+
+	once := components.NewAnimation(testFrames(3), []time.Duration{50 * ms, 50 * ms, 50 * ms}, 1)
+	if frame := once.FrameAt(time.Second); frame != 2 {
+		t.Errorf("expected finished animation to stay on the last frame, got %d", frame)
+	}
+	if !once.Finished(time.Second) {
+		t.Error("expected animation to be finished")
+	}
+}
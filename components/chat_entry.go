@@ -0,0 +1,187 @@
+package components
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const maxVisibleSuggestions = 8
+
+type Suggestion struct {
+	Text  string // replaces the word being completed
+	Label string // shown in the suggestion list, Text if empty
+}
+
+// Single line message entry with tab completion. Suggestions are listed in a panel that
+// doesn't take focus, so typing keeps going to the entry:
+//   - tab selects the next suggestion, asking Complete for some if none are shown
+//   - up/down move the selection, enter accepts it and escape hides the list
+type ChatEntry struct {
+	widget.Entry
+
+	// Called when the user changes the text, not when a suggestion is applied
+	OnTextChanged func(string)
+	// Returns the tab completions for the word before the cursor
+	Complete func(word string) []Suggestion
+	// Called on ctrl+f (cmd+f on macOS), which the entry would otherwise swallow
+	OnFind func()
+
+	suggestions []Suggestion
+	selected    int // -1 while no suggestion is applied
+	wordStart   int // rune span of the text replaced by the selected suggestion
+	wordEnd     int
+	applying    bool
+
+	list *fyne.Container
+	box  *fyne.Container
+}
+
+func NewChatEntry() *ChatEntry {
+	e := &ChatEntry{selected: -1}
+	e.ExtendBaseWidget(e)
+	e.Entry.OnChanged = e.changed
+
+	e.list = container.NewVBox()
+	background := canvas.NewRectangle(theme.OverlayBackgroundColor())
+	e.box = container.NewStack(background, e.list)
+	e.box.Hide()
+	return e
+}
+
+// Panel listing the suggestions, meant to be placed right above the entry
+func (e *ChatEntry) SuggestionList() fyne.CanvasObject {
+	return e.box
+}
+
+// Lists suggestions for the word before the cursor without applying any
+func (e *ChatEntry) ShowSuggestions(suggestions []Suggestion) {
+	if len(suggestions) == 0 {
+		e.HideSuggestions()
+		return
+	}
+	if len(suggestions) > maxVisibleSuggestions {
+		suggestions = suggestions[:maxVisibleSuggestions]
+	}
+	e.suggestions = suggestions
+	e.selected = -1
+	e.wordStart, e.wordEnd = e.currentWord()
+	e.refreshList()
+	e.box.Show()
+}
+
+func (e *ChatEntry) HideSuggestions() {
+	e.suggestions = nil
+	e.selected = -1
+	e.box.Hide()
+}
+
+func (e *ChatEntry) TypedShortcut(shortcut fyne.Shortcut) {
+	custom, ok := shortcut.(*desktop.CustomShortcut)
+	if ok && e.OnFind != nil && custom.KeyName == fyne.KeyF && custom.Modifier == fyne.KeyModifierShortcutDefault {
+		e.OnFind()
+		return
+	}
+	e.Entry.TypedShortcut(shortcut)
+}
+
+func (e *ChatEntry) AcceptsTab() bool {
+	return true
+}
+
+func (e *ChatEntry) TypedKey(key *fyne.KeyEvent) {
+	visible := len(e.suggestions) > 0
+	switch {
+	case key.Name == fyne.KeyTab:
+		if !visible {
+			if e.Complete == nil {
+				return
+			}
+			start, end := e.currentWord()
+			e.ShowSuggestions(e.Complete(string([]rune(e.Text)[start:end])))
+		}
+		e.selectSuggestion(e.selected + 1)
+	case key.Name == fyne.KeyDown && visible:
+		e.selectSuggestion(e.selected + 1)
+	case key.Name == fyne.KeyUp && visible:
+		e.selectSuggestion(e.selected - 1)
+	case (key.Name == fyne.KeyReturn || key.Name == fyne.KeyEnter) && visible && e.selected >= 0:
+		e.HideSuggestions()
+	case key.Name == fyne.KeyEscape && visible:
+		e.HideSuggestions()
+	default:
+		e.Entry.TypedKey(key)
+	}
+}
+
+func (e *ChatEntry) changed(text string) {
+	if e.applying {
+		return
+	}
+	e.HideSuggestions()
+	if e.OnTextChanged != nil {
+		e.OnTextChanged(text)
+	}
+}
+
+// Rune span of the word ending at the cursor
+func (e *ChatEntry) currentWord() (int, int) {
+	runes := []rune(e.Text)
+	end := min(e.CursorColumn, len(runes))
+	start := end
+	for start > 0 && runes[start-1] != ' ' {
+		start--
+	}
+	return start, end
+}
+
+// Replaces the completed word with suggestion index, wrapping around the list
+func (e *ChatEntry) selectSuggestion(index int) {
+	if len(e.suggestions) == 0 {
+		return
+	}
+	index = (index + len(e.suggestions)) % len(e.suggestions)
+	text := []rune(e.suggestions[index].Text)
+	runes := []rune(e.Text)
+	rest := runes[min(e.wordEnd, len(runes)):]
+	if len(rest) == 0 || rest[0] != ' ' {
+		rest = append([]rune{' '}, rest...)
+	}
+	newText := append(append(append([]rune{}, runes[:e.wordStart]...), text...), rest...)
+
+	e.selected = index
+	e.wordEnd = e.wordStart + len(text)
+	e.applying = true
+	e.SetText(string(newText))
+	e.applying = false
+	e.CursorColumn = e.wordEnd + 1
+	e.Refresh()
+	e.refreshList()
+}
+
+func (e *ChatEntry) refreshList() {
+	e.list.RemoveAll()
+	for i, suggestion := range e.suggestions {
+		label := suggestion.Label
+		if label == "" {
+			label = suggestion.Text
+		}
+		index := i
+		button := widget.NewButton(label, func() {
+			e.selectSuggestion(index)
+			e.HideSuggestions()
+			if c := fyne.CurrentApp().Driver().CanvasForObject(e); c != nil {
+				c.Focus(e)
+			}
+		})
+		button.Alignment = widget.ButtonAlignLeading
+		button.Importance = widget.LowImportance
+		if i == e.selected {
+			button.Importance = widget.HighImportance
+		}
+		e.list.Add(button)
+	}
+}
@@ -6,7 +6,7 @@ import (
 
 func NewEmote(emote *hasherino.Emote, clickCallback func(string) error) (LazyLoadedWidget, error) {
 	if emote.Animated {
-		return NewEmoteGif(emote, clickCallback, true)
+		return NewAnimatedEmote(emote, clickCallback)
 	}
 	return NewWebpWidget(emote, clickCallback)
 }
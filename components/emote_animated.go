@@ -0,0 +1,179 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/widget"
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+// AnimatedEmote widget shows an animated gif or webp emote.
+// Frames are loaded lazily and advanced by the shared animation clock.
+type AnimatedEmote struct {
+	widget.BaseWidget
+	min fyne.Size
+
+	dst        *canvas.Image
+	lock       sync.Mutex
+	animation  *Animation
+	startedAt  time.Time
+	frame      int
+	cancelLoad context.CancelFunc // set while the animation is loading or playing
+
+	emote         *hasherino.Emote
+	clickCallback func(string) error
+}
+
+func NewAnimatedEmote(emote *hasherino.Emote, clickCallback func(string) error) (*AnimatedEmote, error) {
+	ret := &AnimatedEmote{
+		emote:         emote,
+		clickCallback: clickCallback,
+	}
+	ret.ExtendBaseWidget(ret)
+	ret.dst = &canvas.Image{}
+	ret.dst.FillMode = canvas.ImageFillContain
+	return ret, nil
+}
+
+func (e *AnimatedEmote) CreateRenderer() fyne.WidgetRenderer {
+	return &animatedEmoteRenderer{emote: e}
+}
+
+func (e *AnimatedEmote) MinSize() fyne.Size {
+	return e.min
+}
+
+func (e *AnimatedEmote) SetMinSize(min fyne.Size) {
+	e.min = min
+}
+
+// LazyLoad requests the frames from the shared fetcher and starts playing once they arrive
+func (e *AnimatedEmote) LazyLoad() error {
+	e.lock.Lock()
+	if e.cancelLoad != nil {
+		e.lock.Unlock()
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancelLoad = cancel
+	e.lock.Unlock()
+
+	go func() {
+		img, err := DefaultImageFetcher().Fetch(ctx, e.emote, PriorityVisible)
+		if err == nil && img.Animation == nil {
+			err = errors.New("emote " + e.emote.Name + " is not animated")
+		}
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				log.Println("Error loading animated emote", e.emote.Name, err)
+			}
+			return
+		}
+
+		fyne.Do(func() {
+			e.lock.Lock()
+			if ctx.Err() != nil {
+				e.lock.Unlock()
+				return
+			}
+			e.animation = img.Animation
+			e.startedAt = time.Now()
+			e.frame = e.currentFrame(e.startedAt)
+			e.dst.Image = e.animation.Frames[e.frame]
+			// Subscribing while holding the lock keeps a concurrent LazyUnload from being missed
+			DefaultAnimationClock().Subscribe(e)
+			e.lock.Unlock()
+			e.dst.Refresh()
+		})
+	}()
+	return nil
+}
+
+// LazyUnload stops the animation and releases the frames
+func (e *AnimatedEmote) LazyUnload() error {
+	e.lock.Lock()
+	DefaultAnimationClock().Unsubscribe(e)
+	if e.cancelLoad == nil {
+		e.lock.Unlock()
+		return nil
+	}
+	e.cancelLoad()
+	e.cancelLoad = nil
+	e.animation = nil
+	e.dst.Image = nil
+	e.lock.Unlock()
+	e.dst.Refresh()
+	return nil
+}
+
+func (e *AnimatedEmote) Prefetch() {
+	DefaultImageFetcher().Prefetch(e.emote)
+}
+
+// Looping animations follow the clock's epoch so copies of the same emote stay in sync.
+// Must hold e.lock.
+func (e *AnimatedEmote) currentFrame(now time.Time) int {
+	if e.animation.LoopCount > 0 {
+		return e.animation.FrameAt(now.Sub(e.startedAt))
+	}
+	return e.animation.FrameAt(now.Sub(DefaultAnimationClock().Epoch()))
+}
+
+func (e *AnimatedEmote) animationTick(now time.Time) {
+	e.lock.Lock()
+	if e.animation == nil {
+		e.lock.Unlock()
+		return
+	}
+	frame := e.currentFrame(now)
+	finished := e.animation.LoopCount > 0 && e.animation.Finished(now.Sub(e.startedAt))
+	changed := frame != e.frame
+	if changed {
+		e.frame = frame
+		e.dst.Image = e.animation.Frames[frame]
+	}
+	e.lock.Unlock()
+
+	if changed {
+		e.dst.Refresh()
+	}
+	if finished {
+		DefaultAnimationClock().Unsubscribe(e)
+	}
+}
+
+func (e *AnimatedEmote) Tapped(event *fyne.PointEvent) {
+	if err := e.clickCallback(e.emote.Name + " "); err != nil {
+		log.Println("Error running emote tap callback", err)
+	}
+}
+
+type animatedEmoteRenderer struct {
+	emote *AnimatedEmote
+}
+
+func (r *animatedEmoteRenderer) Destroy() {
+	r.emote.LazyUnload()
+}
+
+func (r *animatedEmoteRenderer) Layout(size fyne.Size) {
+	r.emote.dst.Resize(size)
+}
+
+func (r *animatedEmoteRenderer) MinSize() fyne.Size {
+	return r.emote.MinSize()
+}
+
+func (r *animatedEmoteRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.emote.dst}
+}
+
+func (r *animatedEmoteRenderer) Refresh() {
+	r.emote.dst.Refresh()
+}
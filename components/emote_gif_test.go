@@ -0,0 +1,140 @@
+package components_test
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Hashy-Software/hasherino-go/components"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden images in testdata")
+
+// Every composited frame is laid side by side in a single golden image
+func frameStrip(frames []image.Image) *image.NRGBA {
+	bounds := frames[0].Bounds()
+	strip := image.NewNRGBA(image.Rect(0, 0, bounds.Dx()*len(frames), bounds.Dy()))
+	for i, frame := range frames {
+		offset := image.Pt(i*bounds.Dx(), 0)
+		draw.Draw(strip, bounds.Add(offset).Sub(bounds.Min), frame, bounds.Min, draw.Src)
+	}
+	return strip
+}
+
+func TestGifAnimationGolden(t *testing.T) {
+	samples, err := filepath.Glob(filepath.Join("testdata", "*.gif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) == 0 {
+		t.Fatal("no sample gifs in testdata")
+	}
+
+	for _, sample := range samples {
+		t.Run(filepath.Base(sample), func(t *testing.T) {
+			file, err := os.Open(sample)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			src, err := gif.DecodeAll(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			anim := components.NewGifAnimation(src)
+			if len(anim.Frames) != len(src.Image) {
+				t.Fatalf("expected %d frames, got %d", len(src.Image), len(anim.Frames))
+			}
+			got := frameStrip(anim.Frames)
+
+			goldenPath := sample[:len(sample)-len(".gif")] + ".golden.png"
+			if *updateGolden {
+				out, err := os.Create(goldenPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer out.Close()
+				if err := png.Encode(out, got); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			goldenFile, err := os.Open(goldenPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer goldenFile.Close()
+			golden, err := png.Decode(goldenFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if golden.Bounds() != got.Bounds() {
+				t.Fatalf("expected size %v, got %v", golden.Bounds(), got.Bounds())
+			}
+			for y := got.Bounds().Min.Y; y < got.Bounds().Max.Y; y++ {
+				for x := got.Bounds().Min.X; x < got.Bounds().Max.X; x++ {
+					expected := color.NRGBAModel.Convert(golden.At(x, y))
+					if actual := got.NRGBAAt(x, y); actual != expected {
+						t.Fatalf("frame %d pixel (%d, %d): expected %v, got %v",
+							x/src.Config.Width, x%src.Config.Width, y, expected, actual)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGifAnimationDisposal(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+	green := color.NRGBA{G: 255, A: 255}
+	yellow := color.NRGBA{R: 255, G: 255, A: 255}
+	transparent := color.NRGBA{}
+
+	// Spot checks so the golden images can't silently encode wrong behaviour
+	cases := map[string][]map[image.Point]color.NRGBA{
+		"disposal_background.gif": {
+			{image.Pt(0, 0): red},
+			{image.Pt(2, 2): blue, image.Pt(0, 0): red},
+			{image.Pt(2, 2): transparent, image.Pt(1, 1): red, image.Pt(4, 4): green},
+		},
+		"disposal_previous.gif": {
+			{image.Pt(3, 3): red},
+			{image.Pt(3, 3): blue},
+			{image.Pt(3, 3): red, image.Pt(6, 6): green},
+		},
+		"transparency.gif": {
+			{image.Pt(0, 0): transparent, image.Pt(3, 3): blue},
+			{image.Pt(1, 1): yellow, image.Pt(3, 3): blue, image.Pt(0, 0): transparent},
+			{image.Pt(0, 0): green, image.Pt(1, 7): green, image.Pt(3, 3): blue},
+		},
+	}
+	for name, frames := range cases {
+		file, err := os.Open(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		src, err := gif.DecodeAll(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		anim := components.NewGifAnimation(src)
+		for i, pixels := range frames {
+			for point, expected := range pixels {
+				actual := color.NRGBAModel.Convert(anim.Frames[i].At(point.X, point.Y))
+				if actual != expected {
+					t.Errorf("%s frame %d pixel %v: expected %v, got %v", name, i, point, expected, actual)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package components
+
+import (
+	"container/heap"
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+type FetchPriority int
+
+const (
+	PriorityPrefetch FetchPriority = iota // warms the cache for items that may become visible soon
+	PriorityVisible                       // item is on screen right now
+)
+
+const (
+	defaultFetchWorkers     = 8
+	defaultMemoryCacheBytes = 256 << 20 // of decoded pixels, animations keep every frame decoded
+)
+
+// A single download shared by every caller asking for the same url
+type fetchCall struct {
+	key      string
+	image    hasherino.CacheableImage
+	priority FetchPriority
+	order    uint64 // keeps requests with the same priority FIFO
+	index    int    // position in the queue, -1 once a worker picked it up
+	waiters  int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	result *DecodedImage
+	err    error
+}
+
+type fetchQueue []*fetchCall
+
+func (q fetchQueue) Len() int { return len(q) }
+
+func (q fetchQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].order < q[j].order
+}
+
+func (q fetchQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *fetchQueue) Push(x any) {
+	call := x.(*fetchCall)
+	call.index = len(*q)
+	*q = append(*q, call)
+}
+
+func (q *fetchQueue) Pop() any {
+	old := *q
+	call := old[len(old)-1]
+	old[len(old)-1] = nil
+	call.index = -1
+	*q = old[:len(old)-1]
+	return call
+}
+
+// LRU of decoded images, bounded by the bytes their pixels take
+type imageMemoryCache struct {
+	capacity int
+	size     int
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+type imageMemoryEntry struct {
+	key   string
+	image *DecodedImage
+	size  int
+}
+
+func newImageMemoryCache(capacity int) *imageMemoryCache {
+	return &imageMemoryCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *imageMemoryCache) get(key string) (*DecodedImage, bool) {
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*imageMemoryEntry).image, true
+}
+
+// Images bigger than the whole cache aren't kept
+func (c *imageMemoryCache) add(key string, image *DecodedImage) {
+	size := image.Size()
+	if element, ok := c.items[key]; ok {
+		entry := element.Value.(*imageMemoryEntry)
+		c.size += size - entry.size
+		entry.image, entry.size = image, size
+		c.order.MoveToFront(element)
+	} else {
+		c.items[key] = c.order.PushFront(&imageMemoryEntry{key: key, image: image, size: size})
+		c.size += size
+	}
+	for c.size > c.capacity {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*imageMemoryEntry)
+		c.order.Remove(oldest)
+		delete(c.items, entry.key)
+		c.size -= entry.size
+	}
+}
+
+// Downloads and decodes emote and emoji images with a bounded number of workers.
+// Concurrent requests for the same url share one download, visible items
+// are fetched before prefetched ones, and a download is cancelled once
+// every caller waiting on it gave up.
+type ImageFetcher struct {
+	load func(context.Context, hasherino.CacheableImage) (*DecodedImage, error)
+
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	queue    fetchQueue
+	inflight map[string]*fetchCall
+	memory   *imageMemoryCache
+	order    uint64
+}
+
+func NewImageFetcher(
+	workers int,
+	memoryBytes int,
+	load func(context.Context, hasherino.CacheableImage) (*DecodedImage, error),
+) *ImageFetcher {
+	f := &ImageFetcher{
+		load:     load,
+		inflight: make(map[string]*fetchCall),
+		memory:   newImageMemoryCache(memoryBytes),
+	}
+	f.cond = sync.NewCond(&f.mutex)
+	for range workers {
+		go f.work()
+	}
+	return f
+}
+
+var (
+	defaultImageFetcher     *ImageFetcher
+	defaultImageFetcherOnce sync.Once
+)
+
+// Returns the fetcher shared by every emote widget
+func DefaultImageFetcher() *ImageFetcher {
+	defaultImageFetcherOnce.Do(func() {
+		defaultImageFetcher = NewImageFetcher(defaultFetchWorkers, defaultMemoryCacheBytes, loadImage)
+	})
+	return defaultImageFetcher
+}
+
+// Returns the decoded image, blocking until it's loaded or ctx is done.
+func (f *ImageFetcher) Fetch(ctx context.Context, img hasherino.CacheableImage, priority FetchPriority) (*DecodedImage, error) {
+	key, err := img.GetUrl()
+	if err != nil {
+		return nil, err
+	}
+
+	f.mutex.Lock()
+	if decoded, ok := f.memory.get(key); ok {
+		f.mutex.Unlock()
+		return decoded, nil
+	}
+	call, ok := f.inflight[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(context.Background())
+		call = &fetchCall{
+			key:      key,
+			image:    img,
+			priority: priority,
+			order:    f.order,
+			ctx:      callCtx,
+			cancel:   cancel,
+			done:     make(chan struct{}),
+		}
+		f.order++
+		f.inflight[key] = call
+		heap.Push(&f.queue, call)
+		f.cond.Signal()
+	} else if priority > call.priority && call.index >= 0 {
+		call.priority = priority
+		heap.Fix(&f.queue, call.index)
+	}
+	call.waiters++
+	f.mutex.Unlock()
+
+	select {
+	case <-call.done:
+		return call.result, call.err
+	case <-ctx.Done():
+		f.mutex.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			if call.index >= 0 {
+				heap.Remove(&f.queue, call.index)
+			}
+			call.cancel()
+			if f.inflight[key] == call {
+				delete(f.inflight, key)
+			}
+		}
+		f.mutex.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// Loads the image in the background with a low priority so it's ready when shown.
+func (f *ImageFetcher) Prefetch(img hasherino.CacheableImage) {
+	key, err := img.GetUrl()
+	if err != nil {
+		return
+	}
+	f.mutex.Lock()
+	_, cached := f.memory.get(key)
+	_, loading := f.inflight[key]
+	f.mutex.Unlock()
+	if cached || loading {
+		return
+	}
+	go f.Fetch(context.Background(), img, PriorityPrefetch)
+}
+
+func (f *ImageFetcher) work() {
+	for {
+		f.mutex.Lock()
+		for f.queue.Len() == 0 {
+			f.cond.Wait()
+		}
+		call := heap.Pop(&f.queue).(*fetchCall)
+		f.mutex.Unlock()
+
+		result, err := f.load(call.ctx, call.image)
+
+		f.mutex.Lock()
+		if err == nil {
+			f.memory.add(call.key, result)
+		}
+		if f.inflight[call.key] == call {
+			delete(f.inflight, call.key)
+		}
+		f.mutex.Unlock()
+
+		call.cancel()
+		call.result, call.err = result, err
+		close(call.done)
+	}
+}
@@ -0,0 +1,134 @@
+package components_test
+
+import (
+	"context"
+	"errors"
+	"image"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Hashy-Software/hasherino-go/components"
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+func newTestEmote(id string) *hasherino.Emote {
+	return &hasherino.Emote{Id: id, Source: hasherino.SevenTV, Name: "emote" + id}
+}
+
+func TestImageFetcherCoalescesRequests(t *testing.T) {
+	var loads atomic.Int32
+	release := make(chan struct{})
+	fetcher := components.NewImageFetcher(4, 1<<20, func(ctx context.Context, img hasherino.CacheableImage) (*components.DecodedImage, error) {
+		loads.Add(1)
+		<-release
+		return &components.DecodedImage{Image: image.NewNRGBA(image.Rect(0, 0, 1, 1))}, nil
+	})
+
+	emote := newTestEmote("1")
+	wg := sync.WaitGroup{}
+	results := make([]*components.DecodedImage, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			img, err := fetcher.Fetch(context.Background(), emote, components.PriorityVisible)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = img
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads.Load() != 1 {
+		t.Errorf("expected 1 load, got %d", loads.Load())
+	}
+	for _, img := range results {
+		if img != results[0] {
+			t.Error("expected every caller to share the same decoded image")
+		}
+	}
+
+	// Served from the memory cache
+	if _, err := fetcher.Fetch(context.Background(), emote, components.PriorityVisible); err != nil {
+		t.Fatal(err)
+	}
+	if loads.Load() != 1 {
+		t.Errorf("expected cached image to be reused, got %d loads", loads.Load())
+	}
+}
+
+func TestImageFetcherPriorityAndCancellation(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan string, 10)
+	fetcher := components.NewImageFetcher(1, 1<<20, func(ctx context.Context, img hasherino.CacheableImage) (*components.DecodedImage, error) {
+		emote := img.(*hasherino.Emote)
+		started <- emote.Id
+		if emote.Id == "blocker" {
+			<-release
+		}
+		return &components.DecodedImage{}, ctx.Err()
+	})
+
+	go fetcher.Fetch(context.Background(), newTestEmote("blocker"), components.PriorityVisible)
+	<-started
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancelledErr := make(chan error)
+	go func() {
+		_, err := fetcher.Fetch(cancelledCtx, newTestEmote("cancelled"), components.PriorityVisible)
+		cancelledErr <- err
+	}()
+	go fetcher.Fetch(context.Background(), newTestEmote("prefetch"), components.PriorityPrefetch)
+	time.Sleep(20 * time.Millisecond)
+	go fetcher.Fetch(context.Background(), newTestEmote("visible"), components.PriorityVisible)
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	if err := <-cancelledErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancelled fetch to return context.Canceled, got %v", err)
+	}
+	close(release)
+
+	for _, expected := range []string{"visible", "prefetch"} {
+		select {
+		case id := <-started:
+			if id != expected {
+				t.Errorf("expected %s to load next, got %s", expected, id)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s", expected)
+		}
+	}
+}
+
+func TestImageFetcherBoundsMemoryByDecodedSize(t *testing.T) {
+	loads := map[string]int{}
+	var mutex sync.Mutex
+	fetcher := components.NewImageFetcher(1, 300, func(ctx context.Context, img hasherino.CacheableImage) (*components.DecodedImage, error) {
+		mutex.Lock()
+		loads[img.(*hasherino.Emote).Id]++
+		mutex.Unlock()
+		// 100 bytes a frame, so an animation of 3 fills the cache on its own
+		frames := []image.Image{}
+		for range 3 {
+			frames = append(frames, image.NewNRGBA(image.Rect(0, 0, 5, 5)))
+		}
+		return &components.DecodedImage{Image: frames[0], Animation: components.NewAnimation(frames, nil, 0)}, nil
+	})
+
+	for _, id := range []string{"a", "a", "b", "a"} {
+		if _, err := fetcher.Fetch(context.Background(), newTestEmote(id), components.PriorityVisible); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if loads["a"] != 2 || loads["b"] != 1 {
+		t.Errorf("expected a to be evicted by b, got loads %v", loads)
+	}
+}
@@ -7,4 +7,5 @@ type LazyLoadedWidget interface {
 
 	LazyLoad() error
 	LazyUnload() error
+	Prefetch() // warms caches without showing the image
 }
@@ -1,55 +1,101 @@
 package components
 
 import (
-	"io"
-	"os"
+	"bytes"
+	"context"
+	"image"
+	"image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 
-	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/storage"
 	"github.com/Hashy-Software/hasherino-go/hasherino"
+	"golang.org/x/image/webp"
 )
 
-func tempFileResource(emote *hasherino.Emote) (*fyne.StaticResource, error) {
-	s, err := os.Stat(emote.TempFile)
+// Decoded emote image, shared between every widget showing the same emote.
+type DecodedImage struct {
+	Image     image.Image // static image, or first frame of an animation
+	Animation *Animation  // set for animated gifs and webps
+}
+
+// Bytes taken by the decoded pixels, of every frame for animations
+func (d *DecodedImage) Size() int {
+	size := 0
+	if d.Image != nil {
+		size += imageSize(d.Image)
+	}
+	if d.Animation != nil {
+		for _, frame := range d.Animation.Frames {
+			if frame != d.Image {
+				size += imageSize(frame)
+			}
+		}
+	}
+	return size
+}
+
+func imageSize(img image.Image) int {
+	switch img := img.(type) {
+	case *image.NRGBA:
+		return len(img.Pix)
+	case *image.RGBA:
+		return len(img.Pix)
+	case *image.Paletted:
+		return len(img.Pix) + len(img.Palette)*4
+	case *image.YCbCr:
+		return len(img.Y) + len(img.Cb) + len(img.Cr)
+	default:
+		return img.Bounds().Dx() * img.Bounds().Dy() * 4
+	}
+}
+
+func cachedContent(ctx context.Context, img hasherino.CacheableImage) ([]byte, error) {
+	url, err := img.GetUrl()
+	if err != nil {
+		return nil, err
+	}
+	cache, err := hasherino.GetImageCache()
 	if err != nil {
 		return nil, err
 	}
-	url, err := emote.GetUrl()
+	return cache.Get(ctx, img.GetCacheKey(), url)
+}
+
+// Downloads(or reads from the disk cache) and decodes an image
+func loadImage(ctx context.Context, img hasherino.CacheableImage) (*DecodedImage, error) {
+	content, err := cachedContent(ctx, img)
 	if err != nil {
 		return nil, err
 	}
-	// image hasn't been written to tempfile yet
-	if s.Size() == 0 {
-		parsedUri, err := storage.ParseURI(url)
+	return decodeImage(content)
+}
+
+// Picks a decoder based on the content, since twitch urls don't have an extension
+func decodeImage(content []byte) (*DecodedImage, error) {
+	switch {
+	case bytes.HasPrefix(content, []byte("GIF8")):
+		pix, err := gif.DecodeAll(bytes.NewReader(content))
 		if err != nil {
 			return nil, err
 		}
-		read, err := storage.Reader(parsedUri)
+		return &DecodedImage{Image: pix.Image[0], Animation: NewGifAnimation(pix)}, nil
+	case IsAnimatedWebp(content):
+		anim, err := DecodeAnimatedWebp(bytes.NewReader(content))
 		if err != nil {
 			return nil, err
 		}
-		bytes, err := io.ReadAll(read)
+		return &DecodedImage{Image: anim.Frames[0], Animation: anim.Animation()}, nil
+	case len(content) >= 12 && string(content[:4]) == "RIFF" && string(content[8:12]) == "WEBP":
+		pix, err := webp.Decode(bytes.NewReader(content))
 		if err != nil {
 			return nil, err
 		}
-		err = os.WriteFile(emote.TempFile, bytes, 0666)
+		return &DecodedImage{Image: pix}, nil
+	default:
+		pix, _, err := image.Decode(bytes.NewReader(content))
 		if err != nil {
 			return nil, err
 		}
-		res := fyne.StaticResource{StaticName: url, StaticContent: bytes}
-		return &res, nil
-	}
-	parsedUri, err := storage.ParseURI("file://" + emote.TempFile)
-	if err != nil {
-		return nil, err
-	}
-	read, err := storage.Reader(parsedUri)
-	if err != nil {
-		return nil, err
-	}
-	bytes, err := io.ReadAll(read)
-	if err != nil {
-		return nil, err
+		return &DecodedImage{Image: pix}, nil
 	}
-	return &fyne.StaticResource{StaticName: url, StaticContent: bytes}, err
 }
@@ -2,9 +2,12 @@ package components
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"image"
 	"io"
 	"log"
+	"sync"
 
 	"github.com/Hashy-Software/hasherino-go/hasherino"
 	"golang.org/x/image/webp"
@@ -22,18 +25,42 @@ type WebpWidget struct {
 	min fyne.Size
 
 	dst           *canvas.Image
-	emote         *hasherino.Emote
+	image         hasherino.CacheableImage
+	text          string // inserted in the chat entry when tapped
 	clickCallback func(string) error
+
+	loadLock   sync.Mutex
+	cancelLoad context.CancelFunc // set while the image is loading or loaded
 }
 
 func NewWebpWidget(emote *hasherino.Emote, clickCallback func(string) error) (*WebpWidget, error) {
 	ret := newAnimatedGif()
 	ret.loadEmptyDst()
-	ret.emote = emote
+	ret.image = emote
+	ret.text = emote.Name
 	ret.clickCallback = clickCallback
 	return ret, nil
 }
 
+// NewEmojiWidget shows emoji as a Twemoji image
+func NewEmojiWidget(emoji *hasherino.Emoji, clickCallback func(string) error) *WebpWidget {
+	ret := newAnimatedGif()
+	ret.loadEmptyDst()
+	ret.image = emoji
+	ret.text = emoji.Emoji
+	ret.clickCallback = clickCallback
+	return ret
+}
+
+// NewImageWidget shows any cached image, like profile pictures, without a tap action
+func NewImageWidget(img hasherino.CacheableImage, min fyne.Size) *WebpWidget {
+	ret := newAnimatedGif()
+	ret.loadEmptyDst()
+	ret.image = img
+	ret.SetMinSize(min)
+	return ret
+}
+
 func (g *WebpWidget) loadEmptyDst() {
 	if emptyImg == nil {
 		img := canvas.NewImageFromImage(image.NewNRGBA(image.Rect(0, 0, 1, 1)))
@@ -116,21 +143,76 @@ func (g *WebpRenderer) Refresh() {
 	g.webp.dst.Refresh()
 }
 
+// LazyLoad requests the image from the shared fetcher and returns immediately
 func (g *WebpWidget) LazyLoad() error {
-	res, err := tempFileResource(g.emote)
-	if err != nil {
-		return err
+	g.loadLock.Lock()
+	if g.cancelLoad != nil {
+		g.loadLock.Unlock()
+		return nil
 	}
-	return g.LoadResource(res)
+	ctx, cancel := context.WithCancel(context.Background())
+	g.cancelLoad = cancel
+	source := g.image
+	g.loadLock.Unlock()
+
+	go func() {
+		img, err := DefaultImageFetcher().Fetch(ctx, source, PriorityVisible)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				log.Println("Error loading image", g.text, err)
+			}
+			return
+		}
+		fyne.Do(func() {
+			g.loadLock.Lock()
+			if ctx.Err() == nil {
+				g.dst.Image = img.Image
+			}
+			g.loadLock.Unlock()
+			g.dst.Refresh()
+		})
+	}()
+	return nil
 }
 
 func (g *WebpWidget) LazyUnload() error {
+	g.loadLock.Lock()
+	if g.cancelLoad == nil {
+		g.loadLock.Unlock()
+		return nil
+	}
+	g.cancelLoad()
+	g.cancelLoad = nil
 	g.loadEmptyDst()
+	g.loadLock.Unlock()
+	g.dst.Refresh()
 	return nil
 }
 
+// SetImage switches the widget to img, keeping what's loaded if it's the same image.
+// Call LazyLoad afterwards to show it.
+func (g *WebpWidget) SetImage(img hasherino.CacheableImage) {
+	g.loadLock.Lock()
+	same := g.image != nil && g.image.GetCacheKey() == img.GetCacheKey()
+	g.loadLock.Unlock()
+	if same {
+		return
+	}
+	g.LazyUnload()
+	g.loadLock.Lock()
+	g.image = img
+	g.loadLock.Unlock()
+}
+
+func (g *WebpWidget) Prefetch() {
+	DefaultImageFetcher().Prefetch(g.image)
+}
+
 func (g *WebpWidget) Tapped(event *fyne.PointEvent) {
-	if err := g.clickCallback(g.emote.Name + " "); err != nil {
+	if g.clickCallback == nil {
+		return
+	}
+	if err := g.clickCallback(g.text + " "); err != nil {
 		log.Println("Error running emote tap callback", err)
 	}
 }
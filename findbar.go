@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+// Entry that reports escape, which widget.Entry ignores
+type escapableEntry struct {
+	widget.Entry
+	onEscape func()
+}
+
+func newEscapableEntry(onEscape func()) *escapableEntry {
+	e := &escapableEntry{onEscape: onEscape}
+	e.ExtendBaseWidget(e)
+	return e
+}
+
+func (e *escapableEntry) TypedKey(key *fyne.KeyEvent) {
+	if key.Name == fyne.KeyEscape {
+		e.onEscape()
+		return
+	}
+	e.Entry.TypedKey(key)
+}
+
+// Hidden bar above a tab's messages, filtering them as its search is typed
+type findBar struct {
+	box   *fyne.Container
+	entry *escapableEntry
+	mode  *widget.Select
+	count *widget.Label
+
+	// Called with the new search, nil when it's cleared or the bar is closed
+	onChanged func(finder *hasherino.MessageFinder)
+	onClosed  func()
+}
+
+func newFindBar(onChanged func(finder *hasherino.MessageFinder), onClosed func()) *findBar {
+	f := &findBar{onChanged: onChanged, onClosed: onClosed}
+	f.entry = newEscapableEntry(f.Close)
+	f.entry.SetPlaceHolder("Find in tab")
+	f.entry.OnChanged = func(string) {
+		f.update()
+	}
+	modes := []string{}
+	for _, mode := range hasherino.FindModes {
+		modes = append(modes, mode.String())
+	}
+	f.mode = widget.NewSelect(modes, func(string) {
+		f.update()
+	})
+	f.mode.SetSelectedIndex(int(hasherino.FindText))
+	f.count = widget.NewLabel("")
+	f.box = container.NewBorder(nil, nil, f.mode, container.NewHBox(f.count, widget.NewButton("✕", f.Close)), f.entry)
+	f.box.Hide()
+	return f
+}
+
+func (f *findBar) update() {
+	if f.onChanged == nil || !f.box.Visible() {
+		return
+	}
+	if f.entry.Text == "" {
+		f.count.SetText("")
+		f.onChanged(nil)
+		return
+	}
+	finder, err := hasherino.NewMessageFinder(hasherino.FindMode(f.mode.SelectedIndex()), f.entry.Text)
+	if err != nil {
+		f.count.SetText("Invalid regex")
+		return
+	}
+	f.onChanged(finder)
+}
+
+// Shows how many lines match the current search
+func (f *findBar) SetCount(count int) {
+	if f.entry.Text != "" {
+		f.count.SetText(strconv.Itoa(count) + " found")
+	}
+}
+
+func (f *findBar) Open(window fyne.Window) {
+	f.box.Show()
+	window.Canvas().Focus(f.entry)
+	f.entry.TypedShortcut(&fyne.ShortcutSelectAll{})
+	f.update()
+}
+
+func (f *findBar) Close() {
+	if !f.box.Visible() {
+		return
+	}
+	f.box.Hide()
+	f.onChanged(nil)
+	f.onClosed()
+}
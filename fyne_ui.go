@@ -4,6 +4,9 @@ import (
 	"errors"
 	"log"
 	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -11,6 +14,7 @@ import (
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
@@ -26,8 +30,10 @@ import (
 )
 
 var (
-	callbackMap      = make(map[string]func(hasherino.ChatMessage))
+	jumpMap          = make(map[string]func(messageId string) bool) // scrolls a tab to one of its messages
+	findMap          = make(map[string]func())                      // opens a tab's find bar
 	defaultEmoteSize = fyne.NewSize(45, 45)
+	defaultBadgeSize = fyne.NewSize(18, 18)
 )
 
 func NewSettingsTabs(hc *hasherino.HasherinoController, w fyne.Window) *container.AppTabs {
@@ -185,9 +191,123 @@ func NewSettingsTabs(hc *hasherino.HasherinoController, w fyne.Window) *containe
 		}
 	}
 	historyChoice.Checked = settings.ChatHistory
+	historyUrlEntry := widget.NewEntry()
+	historyUrlEntry.SetPlaceHolder(hasherino.DefaultRecentMessagesUrl)
+	historyUrlEntry.SetText(settings.ChatHistoryUrl)
+	historyUrlEntry.Validator = func(s string) error {
+		if s == "" {
+			return nil
+		}
+		_, err := url.ParseRequestURI(s)
+		return err
+	}
+	historyUrlEntry.OnChanged = func(s string) {
+		if historyUrlEntry.Validate() != nil {
+			return
+		}
+		settings.ChatHistoryUrl = s
+		if err := hc.SetSettings(settings); err != nil {
+			log.Println(err)
+		}
+	}
+	historyLogsChoice := widget.NewCheck("", func(b bool) {
+		settings.ChatHistoryFromLogs = b
+		err = hc.SetSettings(settings)
+		if err != nil {
+			dialog.ShowError(err, w)
+		}
+	})
+	historyLogsChoice.Checked = settings.ChatHistoryFromLogs
+	twemojiChoice := widget.NewCheck("", func(b bool) {
+		settings.TwemojiRendering = b
+		err = hc.SetSettings(settings)
+		if err != nil {
+			dialog.ShowError(err, w)
+		}
+	})
+	twemojiChoice.Checked = settings.TwemojiRendering
+	cacheSizeLabel := widget.NewLabel("")
+	refreshCacheSize := func() {
+		size, err := hc.GetImageCacheSize()
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		cacheSizeLabel.SetText(strconv.FormatFloat(float64(size)/(1024*1024), 'f', 1, 64) + " MB")
+	}
+	go refreshCacheSize()
+	clearCacheButton := widget.NewButton("Clear cache", func() {
+		err := hc.ClearImageCache()
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		refreshCacheSize()
+	})
+	logRawChoice := widget.NewCheck("", func(b bool) {
+		settings.ChatLogRaw = b
+		err = hc.SetSettings(settings)
+		if err != nil {
+			dialog.ShowError(err, w)
+		}
+	})
+	logRawChoice.Checked = settings.ChatLogRaw
+	// 0 turns the limit off, negative numbers aren't saved
+	parseLimit := func(s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		if err == nil && n < 0 {
+			err = errors.New("can't be negative")
+		}
+		return n, err
+	}
+	newNumberEntry := func(value int, set func(int)) *widget.Entry {
+		entry := widget.NewEntry()
+		entry.SetText(strconv.Itoa(value))
+		entry.Validator = func(s string) error {
+			_, err := parseLimit(s)
+			return err
+		}
+		entry.OnChanged = func(s string) {
+			n, err := parseLimit(s)
+			if err != nil {
+				return
+			}
+			set(n)
+			if err := hc.SetSettings(settings); err != nil {
+				log.Println(err)
+			}
+		}
+		return entry
+	}
+	logSizeEntry := newNumberEntry(settings.ChatLogMaxSizeMB, func(n int) {
+		settings.ChatLogMaxSizeMB = n
+	})
+	logRetentionEntry := newNumberEntry(settings.ChatLogRetentionDays, func(n int) {
+		settings.ChatLogRetentionDays = n
+	})
+	openLogsButton := widget.NewButton("Open logs folder", func() {
+		folder, err := hasherino.GetLogsFolder()
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		if err := os.MkdirAll(folder, 0o755); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		fyne.CurrentApp().OpenURL(&url.URL{Scheme: "file", Path: filepath.ToSlash(folder)})
+	})
 	generalBox := container.NewVBox(
 		container.NewHBox(widget.NewLabel("Chat message limit"), layout.NewSpacer(), chatLimitEntry),
 		container.NewHBox(widget.NewLabel("Chat history"), layout.NewSpacer(), historyChoice),
+		container.NewBorder(nil, nil, widget.NewLabel("Chat history service"), nil, historyUrlEntry),
+		container.NewHBox(widget.NewLabel("Chat history from logs"), layout.NewSpacer(), historyLogsChoice),
+		container.NewHBox(widget.NewLabel("Emoji picker shows Twemoji images"), layout.NewSpacer(), twemojiChoice),
+		container.NewHBox(widget.NewLabel("Image cache"), layout.NewSpacer(), cacheSizeLabel, clearCacheButton),
+		container.NewHBox(widget.NewLabel("Log raw IRC lines"), layout.NewSpacer(), logRawChoice),
+		container.NewHBox(widget.NewLabel("Log file size cap in MB (0 for none)"), layout.NewSpacer(), logSizeEntry),
+		container.NewHBox(widget.NewLabel("Keep logs for days (0 for forever)"), layout.NewSpacer(), logRetentionEntry),
+		container.NewHBox(widget.NewLabel("Logging is enabled per tab"), layout.NewSpacer(), openLogsButton),
 		widget.NewLabel(""),
 		widget.NewLabel(""),
 		widget.NewLabel(""),
@@ -197,6 +317,8 @@ func NewSettingsTabs(hc *hasherino.HasherinoController, w fyne.Window) *containe
 	tabs := container.NewAppTabs(
 		container.NewTabItem("General", generalBox),
 		container.NewTabItem("Accounts", accountsBox),
+		container.NewTabItem("Highlights", NewHighlightSettings(hc, w)),
+		container.NewTabItem("Ignores", NewIgnoreSettings(hc, w)),
 	)
 	return tabs
 }
@@ -230,69 +352,478 @@ func NewEmoteCanvasObject(emote *hasherino.Emote) (*fyne.CanvasObject, error) {
 	return &imgContainer, nil
 }
 
-func NewChatTab(
-	channel string,
-	sendMsg func(string) error,
-	getEmotes func(string) ([]*hasherino.Emote, error),
-	window fyne.Window,
+// A grid of lazily loaded widgets inside a scroll
+type lazyGrid struct {
+	grid    *fyne.Container
+	widgets []components.LazyLoadedWidget
+}
+
+// Loads the widgets visible in scroll, prefetches the ones up to a page below and unloads the rest.
+// Widget positions are relative to their grid, so the grid's offset in the scroll is added.
+func lazyLoadVisible(scroll *container.Scroll, grids []lazyGrid) {
+	scrollOffset := scroll.Offset
+	scrollSize := scroll.Size()
+	for _, g := range grids {
+		gridPos := g.grid.Position()
+		for _, w := range g.widgets {
+			widgetY := gridPos.Y + w.Position().Y
+			widgetSize := w.Size()
+			isVisible := widgetY+widgetSize.Height > scrollOffset.Y &&
+				widgetY < scrollOffset.Y+scrollSize.Height
+			// Images up to a page below the visible area are fetched ahead of time
+			isNext := widgetY >= scrollOffset.Y+scrollSize.Height &&
+				widgetY < scrollOffset.Y+2*scrollSize.Height
+			if isVisible {
+				w.LazyLoad()
+			} else {
+				w.LazyUnload()
+				if isNext {
+					w.Prefetch()
+				}
+			}
+		}
+	}
+}
+
+// Emoji picker section, grouped like the unicode emoji list with a skin tone selector.
+// Returns the image widgets so they can be unloaded when the picker closes.
+func NewEmojiSection(
+	emoji []*hasherino.Emoji,
 	settingsFunc func() (*hasherino.AppSettings, error),
-) *container.TabItem {
-	var data []string = []string{}
-	messageList := widget.NewList(
+	setSkinTone func(hasherino.SkinTone) error,
+	insert func(string) error,
+) (fyne.CanvasObject, *[]components.LazyLoadedWidget) {
+	settings, err := settingsFunc()
+	if err != nil {
+		log.Println(err)
+		settings = &hasherino.AppSettings{}
+	}
+
+	var lazyWidgets []components.LazyLoadedWidget
+	var grids []lazyGrid
+	groups := container.NewVBox()
+	scroll := container.NewScroll(groups)
+	scroll.OnScrolled = func(fyne.Position) {
+		lazyLoadVisible(scroll, grids)
+	}
+
+	build := func() {
+		for _, w := range lazyWidgets {
+			w.LazyUnload()
+		}
+		lazyWidgets, grids = nil, nil
+		groups.RemoveAll()
+
+		var grid *fyne.Container
+		var group string
+		for _, e := range emoji {
+			if grid == nil || e.Group != group {
+				group = e.Group
+				grid = container.NewGridWrap(defaultEmoteSize)
+				grids = append(grids, lazyGrid{grid: grid})
+				groups.Add(widget.NewLabelWithStyle(group, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+				groups.Add(grid)
+			}
+			toned := *e
+			toned.Emoji = e.WithSkinTone(settings.EmojiSkinTone)
+			if settings.TwemojiRendering {
+				emojiWidget := components.NewEmojiWidget(&toned, insert)
+				lazyWidgets = append(lazyWidgets, emojiWidget)
+				grids[len(grids)-1].widgets = append(grids[len(grids)-1].widgets, emojiWidget)
+				grid.Add(emojiWidget)
+			} else {
+				grid.Add(widget.NewButton(toned.Emoji, func() {
+					insert(toned.Emoji + " ")
+				}))
+			}
+		}
+		for i := 0; i < min(60, len(lazyWidgets)); i++ {
+			lazyWidgets[i].LazyLoad()
+		}
+	}
+	build()
+
+	skinTone := widget.NewSelect(hasherino.SkinToneNames, nil)
+	skinTone.SetSelectedIndex(int(settings.EmojiSkinTone))
+	skinTone.OnChanged = func(string) {
+		settings.EmojiSkinTone = hasherino.SkinTone(skinTone.SelectedIndex())
+		if err := setSkinTone(settings.EmojiSkinTone); err != nil {
+			log.Println(err)
+		}
+		build()
+		lazyLoadVisible(scroll, grids)
+	}
+
+	content := container.NewBorder(
+		container.NewHBox(widget.NewLabel("Skin tone"), skinTone),
+		nil, nil, nil,
+		scroll,
+	)
+	return content, &lazyWidgets
+}
+
+// Lists the chatters of channel with a search, broadcaster and staff first.
+// Tapping one opens their user card.
+func ShowChattersWindow(hc *hasherino.HasherinoController, channel string, showUserCard func(login string)) {
+	newWindow := fyne.CurrentApp().NewWindow("Chatters - " + channel)
+	newWindow.Resize(fyne.NewSize(300, 600))
+
+	roleOrder := []string{"broadcaster", "staff", "moderator", "vip"}
+	role := func(chatter *hasherino.Chatter) int {
+		for _, badge := range hasherino.ParseBadges(chatter.Badges) {
+			if i := slices.Index(roleOrder, badge.Name); i >= 0 {
+				return i
+			}
+		}
+		return len(roleOrder)
+	}
+
+	var chatters []*hasherino.Chatter
+	list := widget.NewList(
 		func() int {
-			return len(data)
+			return len(chatters)
 		},
 		func() fyne.CanvasObject {
-			label := widget.NewLabel("template")
-			label.Wrapping = fyne.TextWrapWord
-			return label
+			return widget.NewLabel("template")
 		},
 		func(i widget.ListItemID, o fyne.CanvasObject) {
-			o.(*widget.Label).SetText(data[i])
+			text := chatters[i].Name()
+			if r := role(chatters[i]); r < len(roleOrder) {
+				text += " (" + roleOrder[r] + ")"
+			}
+			o.(*widget.Label).SetText(text)
 		})
-	callbackMap[channel] = func(message hasherino.ChatMessage) {
-		if message.Command != "PRIVMSG" {
+	list.OnSelected = func(i widget.ListItemID) {
+		showUserCard(chatters[i].Login)
+		list.UnselectAll()
+	}
+	count := widget.NewLabel("")
+
+	search := widget.NewEntry()
+	search.SetPlaceHolder("Search chatters")
+	refresh := func() {
+		result, err := hc.GetChatters(channel, search.Text)
+		if err != nil {
+			dialog.ShowError(err, newWindow)
 			return
 		}
-		text := message.Author + ": " + message.Text
+		slices.SortStableFunc(result, func(a, b *hasherino.Chatter) int {
+			return role(a) - role(b)
+		})
+		chatters = result
+		count.SetText(strconv.Itoa(len(chatters)) + " chatters")
+		list.Refresh()
+	}
+	search.OnChanged = func(string) {
+		refresh()
+	}
 
-		settings, err := settingsFunc()
-		if err != nil {
+	newWindow.SetContent(windowContent(container.NewBorder(container.NewBorder(nil, nil, nil, count, search), nil, nil, nil, list)))
+	refresh()
+	newWindow.Show()
+
+	go func() {
+		// Only moderators can see the full list, everyone else gets the ones seen chatting
+		if err := hc.FetchChatters(channel); err != nil {
 			log.Println(err)
 			return
 		}
-		if len(data) >= settings.ChatMessageLimit {
-			data = append(data[1:], text)
+		fyne.Do(refresh)
+	}()
+}
+
+// Messages of a channel with everything to chat in it
+type chatPane struct {
+	content   fyne.CanvasObject
+	chrome    []fyne.CanvasObject // what's around the messages
+	setWindow func(window fyne.Window)
+}
+
+// Shows the pane's dialogs and focuses its entry in window, for when it's popped out or back
+func (p *chatPane) SetWindow(window fyne.Window) {
+	p.setWindow(window)
+}
+
+// Shows only the messages, for overlays
+func (p *chatPane) SetReducedChrome(reduced bool) {
+	for _, object := range p.chrome {
+		if reduced {
+			object.Hide()
 		} else {
-			data = append(data, text)
+			object.Show()
 		}
-		messageList.ScrollToBottom()
+	}
+}
+
+// Pane of channel, shown in a split of a page or a window of its own
+func NewChatPane(
+	channel string,
+	hc *hasherino.HasherinoController,
+	window fyne.Window,
+) *chatPane {
+	// Resized when the settings change, so a changed limit applies right away
+	store := hasherino.NewMessageStore(0)
+	if settings, err := hc.GetSettings(); err == nil {
+		store.SetCapacity(settings.ChatMessageLimit)
+	} else {
+		log.Println(err)
+	}
+	hc.SetSettingsCallback(channel, func(settings *hasherino.AppSettings) {
+		store.SetCapacity(settings.ChatMessageLimit)
+	})
+	showUserCard := func(login string) {
+		recent := []hasherino.ChatMessage{}
+		for _, line := range store.ByUser(login) {
+			recent = append(recent, line.Message)
+		}
+		ShowUserCard(hc, channel, login, recent)
+	}
+	// Open thread windows by thread id, refreshed when one of their messages arrives. Only used
+	// on the UI goroutine.
+	threads := make(map[string]func())
+	showThread := func(threadId string) {
+		if _, open := threads[threadId]; open {
+			return
+		}
+		threads[threadId] = ShowThreadWindow(hc, channel, threadId, store.Messages, showUserCard, func() {
+			delete(threads, threadId)
+		})
+	}
+
+	var replyTo *hasherino.ChatMessage
+	replyLabel := widget.NewLabel("")
+	replyLabel.Truncation = fyne.TextTruncateEllipsis
+	var replyBar *fyne.Container
+	cancelReply := func() {
+		replyTo = nil
+		replyBar.Hide()
+	}
+	replyBar = container.NewBorder(nil, nil, nil, widget.NewButton("✕", cancelReply), replyLabel)
+	replyBar.Hide()
+	var startReply func(message hasherino.ChatMessage)
+
+	// While the find bar has a search, the list only shows its matches. Both are only used on the
+	// UI goroutine, store changes are handed over to it.
+	var finder *hasherino.MessageFinder
+	var matches []*hasherino.StoredMessage
+
+	messageList := widget.NewList(
+		func() int {
+			if finder != nil {
+				return len(matches)
+			}
+			return store.Len()
+		},
+		func() fyne.CanvasObject {
+			return newChatLineRow(showUserCard, func(message hasherino.ChatMessage) {
+				startReply(message)
+			}, showThread)
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			if finder != nil && i < len(matches) {
+				o.(*chatLineRow).SetLine(matches[i], finder.TextRegex())
+			} else if line := store.At(i); finder == nil && line != nil {
+				o.(*chatLineRow).SetLine(line, nil)
+			}
+		})
+	var find *findBar
+	refilter := func() {
+		// A new slice, rows being drawn keep the lines they were given
+		var filtered []*hasherino.StoredMessage
+		if finder != nil {
+			for _, line := range store.Messages() {
+				if finder.Matches(&line.Message) {
+					filtered = append(filtered, line)
+				}
+			}
+			find.SetCount(len(filtered))
+		}
+		matches = filtered
 		messageList.Refresh()
 	}
-	go func() {
-		settings, err := settingsFunc()
-		if err != nil {
-			log.Println(err)
+	// Changed from the chat connection's goroutine as well as the UI's
+	store.SetOnChange(func(change hasherino.MessageStoreChange) {
+		fyne.Do(func() {
+			if change == hasherino.MessageAdded {
+				messageList.ScrollToBottom()
+			}
+			refilter()
+		})
+	})
+	addLine := func(line *hasherino.StoredMessage) {
+		// Reloaded after a reconnect, while live messages kept coming
+		if line.Message.Historical() {
+			store.Insert(line)
+			return
+		}
+		store.Add(line)
+	}
+	hc.SetChatCallback(channel, func(message hasherino.ChatMessage) {
+		switch message.Command {
+		case "CLEARMSG":
+			store.MarkDeleted(message.Tags["target-msg-id"])
+			return
+		case "CLEARCHAT":
+			// Text is the timed out or banned user, empty when the whole chat was cleared
+			store.MarkUserDeleted(message.Text)
+			return
+		case hasherino.SystemCommand:
+			addLine(&hasherino.StoredMessage{Message: message})
+			return
+		case "PRIVMSG":
+		default:
 			return
 		}
-		if !settings.ChatHistory {
+
+		// Already there if the history loaded after it was sent
+		if id := message.Id(); id != "" && store.Get(id) != nil {
 			return
 		}
-		historyMsgs, err := hasherino.GetChatHistory(channel, settings.ChatMessageLimit)
+		badges := hc.GetMessageBadges(&message)
+		if message.Tags["custom-reward-id"] != "" {
+			// The redemption may have arrived before its message
+			pending := store.FindRecent(redemptionMatchLines, func(line *hasherino.StoredMessage) bool {
+				return line.Redemption != nil && line.Message.Id() == "" && matchesRedemption(message, line.Redemption)
+			})
+			if pending != nil {
+				store.Modify(pending, func(line *hasherino.StoredMessage) {
+					line.Message = message
+					line.Badges = badges
+				})
+				return
+			}
+		}
+		addLine(&hasherino.StoredMessage{Message: message, Badges: badges})
+		fyne.Do(func() {
+			if refresh, open := threads[message.ThreadId()]; open {
+				refresh()
+			}
+		})
+	})
+
+	jumpMap[channel] = func(messageId string) bool {
+		find.Close()
+		i := store.IndexOf(messageId)
+		if i < 0 {
+			return false
+		}
+		messageList.ScrollTo(i)
+		messageList.Select(i)
+		return true
+	}
+
+	pollPanel := newVotingPanel()
+	predictionPanel := newVotingPanel()
+	autoMod := newAutoModPane(hc, channel, window)
+	// Sent from the eventsub goroutine
+	hc.SetChannelEventCallback(channel, func(event hasherino.ChannelEvent) {
+		fyne.Do(func() {
+			switch {
+			case event.Redemption != nil:
+				redemption := event.Redemption
+				if redemption.UserInput != "" {
+					sent := store.FindRecent(redemptionMatchLines, func(line *hasherino.StoredMessage) bool {
+						return line.Redemption == nil && matchesRedemption(line.Message, redemption)
+					})
+					if sent != nil {
+						store.Modify(sent, func(line *hasherino.StoredMessage) {
+							line.Redemption = redemption
+						})
+						return
+					}
+				}
+				addLine(newRedemptionLine(channel, redemption))
+			case event.Poll != nil:
+				pollPanel.SetPoll(event.Poll)
+			case event.Prediction != nil:
+				predictionPanel.SetPrediction(event.Prediction)
+			case event.AutoMod != nil:
+				autoMod.Update(event.AutoMod)
+			}
+		})
+	})
+	go func() {
+		settings, err := hc.GetSettings()
 		if err != nil {
 			log.Println(err)
 			return
 		}
-		callback, ok := callbackMap[channel]
-		if !ok {
-			log.Printf("No callback for channel %s.", channel)
+		historyMsgs, err := hc.GetChatHistory(channel, settings.ChatMessageLimit)
+		if err != nil {
+			log.Println(err)
 			return
 		}
-		for _, msg := range *historyMsgs {
-			callback(msg)
+		history := []*hasherino.StoredMessage{}
+		for _, msg := range historyMsgs {
+			if msg.Command != "PRIVMSG" || !hc.ProcessMessage(&msg) {
+				continue
+			}
+			history = append(history, &hasherino.StoredMessage{
+				Message: msg,
+				Badges:  hc.GetMessageBadges(&msg),
+				Deleted: msg.Tags["rm-deleted"] == "1",
+			})
 		}
+		// Live messages that arrived while loading stay after the history
+		store.Prepend(history)
 	}()
-	msgEntry := widget.NewEntry()
+	msgEntry := components.NewChatEntry()
+	find = newFindBar(func(newFinder *hasherino.MessageFinder) {
+		finder = newFinder
+		refilter()
+		messageList.ScrollToBottom()
+	}, func() {
+		window.Canvas().Focus(msgEntry)
+	})
+	openFind := func() {
+		find.Open(window)
+	}
+	findMap[channel] = openFind
+	msgEntry.OnFind = openFind
+	msgEntry.OnTextChanged = func(s string) {
+		replaced := hasherino.ReplaceEmojiShortcodes(s)
+		if replaced != s {
+			// The cursor stays right after the emoji, wherever the shortcode was typed
+			runes := []rune(s)
+			before := hasherino.ReplaceEmojiShortcodes(string(runes[:min(msgEntry.CursorColumn, len(runes))]))
+			cursor := len([]rune(replaced))
+			if strings.HasPrefix(replaced, before) {
+				cursor = len([]rune(before))
+			}
+			msgEntry.SetText(replaced)
+			msgEntry.CursorColumn = cursor
+			return
+		}
+
+		// Suggest emoji for a :shortcode being typed at the end of the message
+		word := s[strings.LastIndex(s, " ")+1:]
+		if len(word) < 3 || word[0] != ':' {
+			return
+		}
+		suggestions := []components.Suggestion{}
+		for _, emoji := range hasherino.SearchEmojiShortcodes(word[1:], 8) {
+			suggestions = append(suggestions, components.Suggestion{
+				Text:  emoji.Emoji,
+				Label: emoji.Emoji + " :" + emoji.Slug + ":",
+			})
+		}
+		msgEntry.ShowSuggestions(suggestions)
+	}
+	msgEntry.Complete = func(word string) []components.Suggestion {
+		if word == "" || word == "@" {
+			return nil
+		}
+		completions, err := hc.GetCompletions(channel, word, 8)
+		if err != nil {
+			log.Println(err)
+			return nil
+		}
+		suggestions := []components.Suggestion{}
+		for _, completion := range completions {
+			suggestions = append(suggestions, components.Suggestion{Text: completion})
+		}
+		return suggestions
+	}
 	msgEntry.SetPlaceHolder("Message")
 	msgEntry.Validator = func(s string) error {
 		if len(s) > 500 {
@@ -307,31 +838,65 @@ func NewChatTab(
 			return
 		}
 
-		err = sendMsg(text)
+		if replyTo != nil {
+			err = hc.SendReply(channel, text, replyTo.Id())
+		} else {
+			err = hc.SendMessage(channel, text)
+		}
 		if err != nil {
 			dialog.ShowError(err, window)
 			return
 		}
+		cancelReply()
+		go func() {
+			if err := hc.RecordCompletionUsage(channel, text); err != nil {
+				log.Println(err)
+			}
+		}()
 
 		msgEntry.SetText("")
 		messageList.ScrollToBottom()
 		messageList.Refresh()
 	}
-	content := container.NewBorder(nil, container.NewBorder(nil, nil, nil, widget.NewButton("😃", func() {
+	startReply = func(message hasherino.ChatMessage) {
+		replyTo = &message
+		replyLabel.SetText("Replying to @" + message.AuthorName() + ": " + message.ReplyText())
+		replyBar.Show()
+		window.Canvas().Focus(msgEntry)
+	}
+	chattersButton := widget.NewButton("👥", func() {
+		ShowChattersWindow(hc, channel, showUserCard)
+	})
+	settingsButton := widget.NewButtonWithIcon("", theme.SettingsIcon(), func() {
+		showTabSettings(hc, channel, window)
+	})
+	top := container.NewVBox(find.box, autoMod.box, pollPanel.box, predictionPanel.box)
+	bottom := container.NewVBox(replyBar, container.NewBorder(nil, nil, nil, container.NewHBox(settingsButton, chattersButton, widget.NewButton("😃", func() {
 		newWindow := fyne.CurrentApp().NewWindow("Select emote")
 		newWindow.Resize(fyne.NewSize(300, 600))
-		newWindow.SetContent(container.NewCenter(widget.NewLabel("Loading...")))
+		newWindow.SetContent(windowContent(container.NewCenter(widget.NewLabel("Loading..."))))
 
 		loadEmoteSearch := func(search string) (*widget.Accordion, error) {
-			emotes, err := getEmotes(search)
+			emotes, err := hc.GetEmotes(channel, search)
+			if err != nil {
+				dialog.ShowError(err, window)
+				return nil, err
+			}
+
+			emoji, err := hc.GetEmoji(search)
 			if err != nil {
 				dialog.ShowError(err, window)
 				return nil, err
 			}
 
 			var images []fyne.CanvasObject
-			var animatedEmotes []*components.EmoteGif
+			var animatedEmotes []components.LazyLoadedWidget
 			mutex := sync.Mutex{}
+			insert := func(text string) error {
+				msgEntry.SetText(msgEntry.Text + text + " ")
+				newWindow.Close()
+				return nil
+			}
 
 			fourth := len(emotes) / 4
 			emoteSlices := [][]*hasherino.Emote{
@@ -348,11 +913,7 @@ func NewChatTab(
 					defer wg.Done()
 
 					for _, emote := range emoteSlice {
-						imgCanvas, err := components.NewEmote(emote, func(text string) error {
-							msgEntry.SetText(msgEntry.Text + text + " ")
-							newWindow.Close()
-							return nil
-						})
+						imgCanvas, err := components.NewEmote(emote, insert)
 						if err != nil {
 							log.Println(err)
 							continue
@@ -360,53 +921,40 @@ func NewChatTab(
 						mutex.Lock()
 						images = append(images, imgCanvas)
 						if emote.Animated {
-							animatedEmotes = append(animatedEmotes, imgCanvas.(*components.EmoteGif))
+							animatedEmotes = append(animatedEmotes, imgCanvas)
 						}
 						mutex.Unlock()
 					}
 				}(emoteSlice)
 			}
+			emojiSection, emojiWidgets := NewEmojiSection(emoji, hc.GetSettings, hc.SetEmojiSkinTone, insert)
 			newWindow.SetOnClosed(func() {
-				for _, emote := range animatedEmotes {
-					go func(emote *components.EmoteGif) {
-						emote.Stop()
-					}(emote)
+				for _, emote := range append(animatedEmotes, *emojiWidgets...) {
+					emote.LazyUnload()
 				}
 			})
 			wg.Wait()
 			grid := container.NewGridWrap(defaultEmoteSize, images...)
 			stvScroll := container.NewScroll(grid)
-			stvScroll.OnScrolled = func(scrollOffset fyne.Position) {
-				for _, comp := range images {
-					go func(comp fyne.CanvasObject) {
-						w := comp.(components.LazyLoadedWidget)
-						scrollSize := stvScroll.Size()
-						widgetPos := w.Position()
-						widgetSize := w.Size()
-						isVisible := widgetPos.Y+widgetSize.Height > scrollOffset.Y &&
-							widgetPos.Y < scrollOffset.Y+scrollSize.Height
-						if isVisible {
-							w.LazyLoad()
-						} else {
-							w.LazyUnload()
-						}
-					}(comp)
-				}
+			stvGrid := lazyGrid{grid: grid}
+			for _, comp := range images {
+				stvGrid.widgets = append(stvGrid.widgets, comp.(components.LazyLoadedWidget))
+			}
+			stvScroll.OnScrolled = func(fyne.Position) {
+				lazyLoadVisible(stvScroll, []lazyGrid{stvGrid})
 			}
 			// Load the first 60 images
 			// TODO: work for each accordion item
 			start, end := 0, min(60, len(images))
 			for i := start; i < end; i++ {
-				go func(i int) {
-					images[i].(components.LazyLoadedWidget).LazyLoad()
-				}(i)
+				images[i].(components.LazyLoadedWidget).LazyLoad()
 			}
 			accordion := widget.NewAccordion(
 				widget.NewAccordionItem("Twitch Emotes", widget.NewLabel("Not implemented")),
 				widget.NewAccordionItem("7TV Emotes"+strings.Repeat(" ", 80), stvScroll),
 				widget.NewAccordionItem("FFZ Emotes", widget.NewLabel("Not implemented")),
 				widget.NewAccordionItem("BTTV Emotes", widget.NewLabel("Not implemented")),
-				widget.NewAccordionItem("Emoji", widget.NewLabel("Not implemented")),
+				widget.NewAccordionItem("Emoji", emojiSection),
 			)
 			accordion.Items[1].Open = true
 			return accordion, nil
@@ -420,59 +968,126 @@ func NewChatTab(
 				dialog.ShowError(err, window)
 				return
 			}
-			newWindow.SetContent(container.NewBorder(searchEntry, nil, nil, nil, accordion))
+			newWindow.SetContent(windowContent(container.NewBorder(searchEntry, nil, nil, nil, accordion)))
 			newWindow.Canvas().Focus(searchEntry)
 			newWindow.Show()
 		}
 
 		searchEntry.OnChanged("")
-	}), msgEntry), nil, nil, messageList)
-	return container.NewTabItem(channel, content)
+	})), msgEntry))
+	content := container.NewBorder(top, bottom, nil, nil, container.NewStack(
+		messageList,
+		container.NewBorder(nil, msgEntry.SuggestionList(), nil, nil),
+	))
+	return &chatPane{
+		content: content,
+		chrome:  []fyne.CanvasObject{top, bottom},
+		setWindow: func(newWindow fyne.Window) {
+			window = newWindow
+			autoMod.window = newWindow
+		},
+	}
 }
 
 func main() {
 	a := app.New()
+	// Animated emotes don't need to play while the window isn't focused or is minimized
+	a.Lifecycle().SetOnExitedForeground(func() {
+		components.DefaultAnimationClock().SetPaused(true)
+	})
+	a.Lifecycle().SetOnEnteredForeground(func() {
+		components.DefaultAnimationClock().SetPaused(false)
+	})
+	a.Settings().SetTheme(clearTheme{theme.DefaultTheme()})
 	w := a.NewWindow("hasherino2")
 	w.Resize(fyne.NewSize(600, 800))
 	w.SetMaster()
 
 	hc := &hasherino.HasherinoController{}
-	hc, err := hc.New(callbackMap)
+	hc, err := hc.New()
 	if err != nil {
 		panic(err)
 	}
 
-	chatTabs := container.NewAppTabs()
-	chatTabs.OnSelected = func(tab *container.TabItem) {
-		hc.SetSelectedTab(tab.Text)
-		chatTabs.Refresh()
-	}
-
-	sendMessage := func(message string) error {
-		currentTab, err := hc.GetSelectedTab()
-		if err != nil {
-			return err
+	chatTabs := container.NewAppTabs(NewMentionsTab(hc), NewWhispersTab(hc, w))
+	pages := []*chatPage{}
+	pageOf := func(item *container.TabItem) *chatPage {
+		for _, page := range pages {
+			if page.item == item {
+				return page
+			}
 		}
-		_, err = hc.GetActiveAccount()
-		if err != nil {
-			return err
+		return nil
+	}
+	chatTabs.OnSelected = func(item *container.TabItem) {
+		if page := pageOf(item); page != nil {
+			if err := hc.SetSelectedPage(page.page.Id); err != nil {
+				log.Println(err)
+			}
 		}
-		return hc.SendMessage(currentTab.Login, message)
+	}
+	removePage := func(page *chatPage) {
+		pages = slices.DeleteFunc(pages, func(p *chatPage) bool {
+			return p == page
+		})
+		chatTabs.Remove(page.item)
+	}
+	addPage := func(page *hasherino.Page) *chatPage {
+		var newPage *chatPage
+		newPage = newChatPage(hc, w, chatTabs, page, func() {
+			removePage(newPage)
+		})
+		pages = append(pages, newPage)
+		chatTabs.Append(newPage.item)
+		return newPage
 	}
 
 	savedTabs, err := hc.GetTabs()
 	if err == nil {
-		selectedTab, err := hc.GetSelectedTab()
 		var tabIds []string
-
 		for _, tab := range savedTabs {
 			tabIds = append(tabIds, tab.Id)
-			newTab := NewChatTab(tab.Login, sendMessage, hc.GetEmotes, w, hc.GetSettings)
-			chatTabs.Append(newTab)
-			if err == nil && selectedTab.Login == tab.Login {
-				chatTabs.Select(newTab)
+		}
+
+		savedPages, err := hc.GetPages()
+		if err != nil {
+			log.Println(err)
+		}
+		shown := make(map[string]bool)
+		for _, page := range savedPages {
+			newPage := addPage(page)
+			if page.Selected {
+				chatTabs.Select(newPage.item)
 			}
+			for _, channel := range page.Root.Channels() {
+				shown[channel] = true
+			}
+		}
+		// Joined channels missing from the layout still get a page
+		for _, tab := range savedTabs {
+			if shown[tab.Login] {
+				continue
+			}
+			page := &hasherino.Page{Root: &hasherino.LayoutNode{Channel: tab.Login}}
+			if err := hc.SavePage(page); err != nil {
+				log.Println(err)
+				continue
+			}
+			addPage(page)
 		}
+
+		popOuts, err := hc.GetOpenPopOuts()
+		if err != nil {
+			log.Println(err)
+		}
+		for _, popOut := range popOuts {
+			for _, page := range pages {
+				if page.Has(popOut.Channel) {
+					page.popOut(popOut.Channel, popOut.Overlay)
+				}
+			}
+		}
+
 		tempTabErr := hc.AddTempTabs(&tabIds)
 		if tempTabErr != nil {
 			log.Println(tempTabErr)
@@ -487,44 +1102,43 @@ func main() {
 				dialog.ShowCustom("Settings", "Close", container.NewBorder(nil, nil, nil, nil, NewSettingsTabs(hc, w)), w)
 			}),
 			widget.NewButtonWithIcon("Add tab", theme.ContentAddIcon(), func() {
-				entry := widget.NewEntry()
-				items := []*widget.FormItem{
-					widget.NewFormItem("New tab", entry),
-				}
-				var newTabDialog *dialog.FormDialog
-				addTabFunc := func(b bool) {
-					if b {
-						err := hc.AddTab(entry.Text)
-						if err != nil {
-							dialog.ShowError(err, w)
-						} else {
-							chatTabs.Append(NewChatTab(entry.Text, sendMessage, hc.GetEmotes, w, hc.GetSettings))
-							newTabDialog.Hide()
+				showAddChannelDialog("Add tab", w, func(channel string) error {
+					channel, err := hc.AddTab(channel)
+					if err != nil {
+						return err
+					}
+					page := &hasherino.Page{Root: &hasherino.LayoutNode{Channel: channel}}
+					if err := hc.SavePage(page); err != nil {
+						return err
+					}
+					chatTabs.Select(addPage(page).item)
+					return nil
+				})
+			}),
+			widget.NewButtonWithIcon("Search", theme.SearchIcon(), func() {
+				ShowSearchWindow(hc, func(message *hasherino.IndexedMessage) bool {
+					jump, ok := jumpMap[message.Channel]
+					if !ok || message.MessageId == "" {
+						return false
+					}
+					for _, page := range pages {
+						if page.Has(message.Channel) {
+							chatTabs.Select(page.item)
 						}
 					}
-				}
-				newTabDialog = dialog.NewForm("Add tab", "Add", "Cancel", items, addTabFunc, w)
-				entry.SetPlaceHolder("Channel")
-				entry.OnSubmitted = func(_ string) {
-					addTabFunc(true)
-				}
-				newTabDialog.Show()
-
-				w.Canvas().Focus(entry)
+					return jump(message.MessageId)
+				})
 			}),
 			widget.NewButtonWithIcon("Close tab", theme.CancelIcon(), func() {
-				tab, err := hc.GetSelectedTab()
-				if err != nil {
-					dialog.ShowError(err, w)
+				page := pageOf(chatTabs.Selected())
+				if page == nil {
 					return
 				}
-				err = hc.RemoveTab(tab.Id)
-				if err != nil {
+				if err := page.Close(); err != nil {
 					dialog.ShowError(err, w)
 					return
 				}
-				delete(callbackMap, tab.Login)
-				chatTabs.Remove(chatTabs.Selected())
+				removePage(page)
 			}),
 		),
 
@@ -534,6 +1148,26 @@ func main() {
 		chatTabs,
 	)
 
-	w.SetContent(components)
+	// Ctrl+F while a chat entry is focused opens its own find bar, otherwise the first split's
+	w.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyF, Modifier: fyne.KeyModifierShortcutDefault}, func(fyne.Shortcut) {
+		page := pageOf(chatTabs.Selected())
+		if page == nil {
+			return
+		}
+		if openFind, ok := findMap[page.page.Root.Channels()[0]]; ok {
+			openFind()
+		}
+	})
+	// Keeps the split offsets as they were dragged, and the popped out windows as they were left.
+	// Intercepted so it runs before the app starts quitting, while the windows can still tell
+	// where they are.
+	w.SetCloseIntercept(func() {
+		for _, page := range pages {
+			page.save()
+			page.savePopOuts()
+		}
+		w.Close()
+	})
+	w.SetContent(windowContent(components))
 	w.ShowAndRun()
 }
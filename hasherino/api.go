@@ -48,7 +48,7 @@ func (t *TwitchOAuth) OpenOAuthPage(app_id string) {
 		"client_id":     app_id,
 		"redirect_uri":  "http://localhost:17563",
 		"response_type": "token",
-		"scope":         "chat:edit chat:read user:manage:chat_color",
+		"scope":         "chat:edit chat:read user:manage:chat_color moderator:read:chatters moderator:read:followers moderator:manage:banned_users user:manage:blocked_users user:read:blocked_users user:manage:whispers channel:read:redemptions channel:read:polls channel:read:predictions moderator:manage:automod",
 		"state":         t.state,
 	}
 	headersStr := ""
@@ -154,21 +154,23 @@ func NewHelix(appId string) *Helix {
 	}
 }
 
-type HelixUsers struct {
+type HelixUser struct {
 	// https://mholt.github.io/json-to-go/
-	Data []struct {
-		ID              string    `json:"id"`
-		Login           string    `json:"login"`
-		DisplayName     string    `json:"display_name"`
-		Type            string    `json:"type"`
-		BroadcasterType string    `json:"broadcaster_type"`
-		Description     string    `json:"description"`
-		ProfileImageURL string    `json:"profile_image_url"`
-		OfflineImageURL string    `json:"offline_image_url"`
-		ViewCount       int       `json:"view_count"`
-		Email           string    `json:"email"`
-		CreatedAt       time.Time `json:"created_at"`
-	} `json:"data"`
+	ID              string    `json:"id"`
+	Login           string    `json:"login"`
+	DisplayName     string    `json:"display_name"`
+	Type            string    `json:"type"`
+	BroadcasterType string    `json:"broadcaster_type"`
+	Description     string    `json:"description"`
+	ProfileImageURL string    `json:"profile_image_url"`
+	OfflineImageURL string    `json:"offline_image_url"`
+	ViewCount       int       `json:"view_count"`
+	Email           string    `json:"email"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type HelixUsers struct {
+	Data []HelixUser `json:"data"`
 }
 
 func (h *Helix) GetUsers(token string, usernames []string) (*HelixUsers, error) {
@@ -214,60 +216,6 @@ func (h *Helix) GetUsers(token string, usernames []string) (*HelixUsers, error)
 	return &users, nil
 }
 
-type ChatMessagesJson struct {
-	Messages  []string `json:"messages"`
-	Error     any      `json:"error"`
-	ErrorCode any      `json:"error_code"`
-}
-
-func GetChatHistory(channel string, limit int) (*[]ChatMessage, error) {
-	url := "https://recent-messages.robotty.de/api/v2/recent-messages/"
-	url += channel
-	url += "?limit=" + strconv.Itoa(limit)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Printf("Failed to create request for chat history: %s", err)
-		return nil, err
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		log.Printf("Failed to get chat history: %s", err)
-		return nil, err
-	}
-	log.Printf("Chat history status code: %d", resp.StatusCode)
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Failed to read response body: %s", err)
-		return nil, err
-	}
-
-	var messagesJson ChatMessagesJson
-	if err := json.Unmarshal(body, &messagesJson); err != nil {
-		log.Printf("Failed to unmarshal response body: %s", err)
-		return nil, err
-	}
-
-	if messagesJson.Error != nil {
-		e := fmt.Sprintf("Failed to get chat history: %s", messagesJson.Error)
-		log.Printf(e)
-		return nil, errors.New(e)
-	}
-
-	messages := []ChatMessage{}
-	for _, messageStr := range messagesJson.Messages {
-		msg, err := ParseMessage(messageStr)
-		if err != nil {
-			return nil, err
-		}
-		messages = append(messages, *msg)
-	}
-
-	return &messages, nil
-}
-
 type EmoteSet struct {
 	ID     string `json:"id"`
 	Name   string `json:"name"`
@@ -0,0 +1,84 @@
+package hasherino
+
+import (
+	"log"
+	"time"
+)
+
+// Collects items from a hot path, like the chat read loop, and writes them in batches from a
+// goroutine of its own, once size items are queued or interval passes
+type BatchWriter[T any] struct {
+	name     string
+	size     int
+	interval time.Duration
+	write    func([]T) error
+	items    chan T
+	flush    chan chan struct{}
+}
+
+func NewBatchWriter[T any](name string, size int, interval time.Duration, write func([]T) error) *BatchWriter[T] {
+	b := &BatchWriter[T]{
+		name:     name,
+		size:     size,
+		interval: interval,
+		write:    write,
+		items:    make(chan T, size*8),
+		flush:    make(chan chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Queues item without waiting for it to be written. If the writes fall that far behind, the
+// item is dropped rather than stalling the caller.
+func (b *BatchWriter[T]) Add(item T) {
+	select {
+	case b.items <- item:
+	default:
+		log.Printf("%s: queue is full, dropped an item", b.name)
+	}
+}
+
+// Writes everything queued so far, returning once it's done
+func (b *BatchWriter[T]) Flush() {
+	done := make(chan struct{})
+	b.flush <- done
+	<-done
+}
+
+func (b *BatchWriter[T]) run() {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	batch := make([]T, 0, b.size)
+	writeBatch := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := b.write(batch); err != nil {
+			log.Printf("%s: failed to write %d items: %s", b.name, len(batch), err)
+		}
+		batch = make([]T, 0, b.size)
+	}
+
+	add := func(item T) {
+		batch = append(batch, item)
+		if len(batch) >= b.size {
+			writeBatch()
+		}
+	}
+
+	for {
+		select {
+		case item := <-b.items:
+			add(item)
+		case <-ticker.C:
+			writeBatch()
+		case done := <-b.flush:
+			for len(b.items) > 0 {
+				add(<-b.items)
+			}
+			writeBatch()
+			close(done)
+		}
+	}
+}
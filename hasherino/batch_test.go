@@ -0,0 +1,31 @@
+package hasherino_test
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+func TestBatchWriter(t *testing.T) {
+	var mutex sync.Mutex
+	batches := [][]int{}
+	writer := hasherino.NewBatchWriter("test", 3, time.Hour, func(items []int) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		batches = append(batches, items)
+		return nil
+	})
+	for i := 0; i < 4; i++ {
+		writer.Add(i)
+	}
+	writer.Flush()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(batches) != 2 || !slices.Equal(batches[0], []int{0, 1, 2}) || !slices.Equal(batches[1], []int{3}) {
+		t.Errorf("expected a full batch then the flushed rest, got %v", batches)
+	}
+}
@@ -0,0 +1,324 @@
+package hasherino
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultImageCacheSize   = 256 * 1024 * 1024 // Bytes kept on disk before evicting least recently used images
+	defaultImageCacheMaxAge = 24 * time.Hour    // How long an image is used before revalidating it with the server
+	imageCacheMetaExtension = ".meta"
+)
+
+// Anything with an image that can be stored in the image cache, like emotes and emoji
+type CacheableImage interface {
+	GetUrl() (string, error)
+	GetCacheKey() ImageCacheKey
+}
+
+// Identifies a cached image. Every field becomes part of the file path.
+type ImageCacheKey struct {
+	Provider string
+	Id       string
+	Scale    string
+	Format   string
+}
+
+func (k ImageCacheKey) path() string {
+	return filepath.Join(k.Provider, k.Id+"_"+k.Scale+"."+k.Format)
+}
+
+// Stored next to each cached image, used for revalidation.
+type imageCacheMeta struct {
+	Url          string    `json:"url"`
+	ETag         string    `json:"etag"`
+	LastModified string    `json:"last_modified"`
+	ValidatedAt  time.Time `json:"validated_at"`
+}
+
+// On-disk image cache with conditional revalidation and a LRU size cap.
+// Access time is tracked through the file's modification time.
+type ImageCache struct {
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+	client   *http.Client
+
+	mutex sync.Mutex
+	size  int64 // -1 until the folder has been measured
+}
+
+func NewImageCache(dir string, maxBytes int64, maxAge time.Duration) *ImageCache {
+	return &ImageCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		maxAge:   maxAge,
+		client:   http.DefaultClient,
+		size:     -1,
+	}
+}
+
+var (
+	defaultImageCache    *ImageCache
+	defaultImageCacheErr error
+	defaultImageCacheMu  sync.Mutex
+)
+
+// Returns the cache shared by the whole app, stored in the data folder.
+func GetImageCache() (*ImageCache, error) {
+	defaultImageCacheMu.Lock()
+	defer defaultImageCacheMu.Unlock()
+	if defaultImageCache != nil || defaultImageCacheErr != nil {
+		return defaultImageCache, defaultImageCacheErr
+	}
+	dataFolder, err := GetDataFolder()
+	if err != nil {
+		defaultImageCacheErr = err
+		return nil, err
+	}
+	defaultImageCache = NewImageCache(
+		filepath.Join(dataFolder, "cache", "images"),
+		defaultImageCacheSize,
+		defaultImageCacheMaxAge,
+	)
+	return defaultImageCache, nil
+}
+
+// Returns the image for key, downloading it from url if it's missing or stale.
+// If the server can't be reached, a stale copy is returned when available.
+func (c *ImageCache) Get(ctx context.Context, key ImageCacheKey, url string) ([]byte, error) {
+	file := filepath.Join(c.dir, key.path())
+
+	content, meta, err := c.read(file)
+	if err == nil && meta.Url == url && time.Since(meta.ValidatedAt) < c.maxAge {
+		c.touch(file)
+		return content, nil
+	}
+	if err != nil || meta.Url != url {
+		content, meta = nil, &imageCacheMeta{}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if content != nil {
+		if meta.ETag != "" {
+			req.Header.Add("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Add("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if content != nil && ctx.Err() == nil {
+			log.Printf("Failed to revalidate %s, using stale copy: %s", url, err)
+			c.touch(file)
+			return content, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && content != nil:
+		meta.ValidatedAt = time.Now()
+		if err := c.writeMeta(file, meta); err != nil {
+			log.Printf("Failed to update cache metadata for %s: %s", url, err)
+		}
+		c.touch(file)
+		return content, nil
+	case resp.StatusCode == http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		meta = &imageCacheMeta{
+			Url:          url,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			ValidatedAt:  time.Now(),
+		}
+		if err := c.write(file, body, meta); err != nil {
+			log.Printf("Failed to cache %s: %s", url, err)
+		}
+		return body, nil
+	default:
+		if content != nil {
+			log.Printf("Unexpected status %d revalidating %s, using stale copy", resp.StatusCode, url)
+			return content, nil
+		}
+		return nil, errors.New("failed to download " + url + ": status " + strconv.Itoa(resp.StatusCode))
+	}
+}
+
+func (c *ImageCache) read(file string) ([]byte, *imageCacheMeta, error) {
+	metaBytes, err := os.ReadFile(file + imageCacheMetaExtension)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta := &imageCacheMeta{}
+	if err := json.Unmarshal(metaBytes, meta); err != nil {
+		return nil, nil, err
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, meta, nil
+}
+
+func (c *ImageCache) write(file string, content []byte, meta *imageCacheMeta) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	var previousSize int64
+	if s, err := os.Stat(file); err == nil {
+		previousSize = s.Size()
+	}
+	if err := writeFileAtomic(file, content); err != nil {
+		return err
+	}
+	if err := c.writeMeta(file, meta); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.size >= 0 {
+		c.size += int64(len(content)) - previousSize
+	}
+	return c.evict()
+}
+
+func (c *ImageCache) writeMeta(file string, meta *imageCacheMeta) error {
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(file+imageCacheMetaExtension, metaBytes)
+}
+
+// Marks the file as recently used
+func (c *ImageCache) touch(file string) {
+	now := time.Now()
+	if err := os.Chtimes(file, now, now); err != nil {
+		log.Printf("Failed to update cache access time for %s: %s", file, err)
+	}
+}
+
+type imageCacheEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func (c *ImageCache) entries() ([]imageCacheEntry, int64, error) {
+	entries := []imageCacheEntry{}
+	var total int64
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, imageCacheMetaExtension) || strings.HasPrefix(d.Name(), ".tmp") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, imageCacheEntry{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	return entries, total, err
+}
+
+// Removes least recently used images until the cache fits maxBytes. Must hold c.mutex.
+func (c *ImageCache) evict() error {
+	if c.size >= 0 && c.size <= c.maxBytes {
+		return nil
+	}
+	entries, total, err := c.entries()
+	if err != nil {
+		return err
+	}
+	c.size = total
+	if total <= c.maxBytes {
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+	for _, entry := range entries {
+		if c.size <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(entry.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		os.Remove(entry.path + imageCacheMetaExtension)
+		c.size -= entry.size
+	}
+	return nil
+}
+
+// Returns how many bytes the cached images take on disk.
+func (c *ImageCache) Size() (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, total, err := c.entries()
+	if err != nil {
+		return 0, err
+	}
+	c.size = total
+	return total, nil
+}
+
+// Deletes every cached image.
+func (c *ImageCache) Clear() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := os.RemoveAll(c.dir); err != nil {
+		return err
+	}
+	c.size = 0
+	return nil
+}
+
+// Writes to a temporary file in the same folder, then renames it,
+// so readers never see a partially written file.
+func writeFileAtomic(name string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
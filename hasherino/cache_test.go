@@ -0,0 +1,92 @@
+package hasherino_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+func TestImageCacheRevalidation(t *testing.T) {
+	var requests, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("image"))
+	}))
+	defer server.Close()
+
+	key := hasherino.ImageCacheKey{Provider: "7tv", Id: "1", Scale: "2x", Format: "webp"}
+
+	fresh := hasherino.NewImageCache(t.TempDir(), 1024, time.Hour)
+	for range 2 {
+		content, err := fresh.Get(context.Background(), key, server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != "image" {
+			t.Errorf("unexpected content %q", content)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("expected 1 request for a fresh entry, got %d", requests.Load())
+	}
+
+	stale := hasherino.NewImageCache(t.TempDir(), 1024, 0)
+	for range 2 {
+		content, err := stale.Get(context.Background(), key, server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != "image" {
+			t.Errorf("unexpected content %q", content)
+		}
+	}
+	if notModified.Load() != 1 {
+		t.Errorf("expected stale entry to be revalidated, got %d not modified responses", notModified.Load())
+	}
+}
+
+func TestImageCacheEviction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 10))
+	}))
+	defer server.Close()
+
+	cache := hasherino.NewImageCache(t.TempDir(), 25, time.Hour)
+	for _, id := range []string{"1", "2", "3"} {
+		key := hasherino.ImageCacheKey{Provider: "7tv", Id: id, Scale: "2x", Format: "webp"}
+		if _, err := cache.Get(context.Background(), key, server.URL+"/"+id); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond) // distinct access times
+	}
+
+	size, err := cache.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 20 {
+		t.Errorf("expected 20 bytes after eviction, got %d", size)
+	}
+
+	if err := cache.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	size, err = cache.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 0 {
+		t.Errorf("expected empty cache, got %d bytes", size)
+	}
+}
@@ -0,0 +1,94 @@
+package hasherino
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Scores how well query fuzzy matches candidate, ignoring case. Every character of query has to
+// appear in candidate in order; matches at the start, after a word boundary or right after the
+// previous match score higher.
+func FuzzyScore(candidate string, query string) (int, bool) {
+	queryRunes := []rune(strings.ToLower(query))
+	if len(queryRunes) == 0 {
+		return 0, true
+	}
+
+	score := 0
+	matched := 0
+	lastMatch := -2
+	var previous rune
+	for index, r := range []rune(candidate) {
+		if matched == len(queryRunes) {
+			break
+		}
+		if unicode.ToLower(r) == queryRunes[matched] {
+			score++
+			switch {
+			case index == 0:
+				score += 10
+			case index == lastMatch+1:
+				score += 5
+			case previous == '_' || (unicode.IsLower(previous) && unicode.IsUpper(r)):
+				score += 3
+			}
+			lastMatch = index
+			matched++
+		}
+		previous = r
+	}
+	if matched < len(queryRunes) {
+		return 0, false
+	}
+	return score, true
+}
+
+// Returns up to limit candidates matching query, best first. Candidates starting with query
+// come before other fuzzy matches, then the ones used more often.
+func RankCompletions(query string, candidates []string, usage map[string]int, limit int) []string {
+	type rankedCompletion struct {
+		text   string
+		prefix bool
+		uses   int
+		score  int
+	}
+
+	lowerQuery := strings.ToLower(query)
+	ranked := []rankedCompletion{}
+	for _, candidate := range candidates {
+		score, ok := FuzzyScore(candidate, query)
+		if !ok {
+			continue
+		}
+		ranked = append(ranked, rankedCompletion{
+			text:   candidate,
+			prefix: strings.HasPrefix(strings.ToLower(candidate), lowerQuery),
+			uses:   usage[candidate],
+			score:  score,
+		})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.prefix != b.prefix {
+			return a.prefix
+		}
+		if a.uses != b.uses {
+			return a.uses > b.uses
+		}
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if len(a.text) != len(b.text) {
+			return len(a.text) < len(b.text)
+		}
+		return a.text < b.text
+	})
+
+	result := []string{}
+	for i := 0; i < len(ranked) && i < limit; i++ {
+		result = append(result, ranked[i].text)
+	}
+	return result
+}
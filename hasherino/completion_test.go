@@ -0,0 +1,42 @@
+package hasherino_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	if _, ok := hasherino.FuzzyScore("PogChamp", "pgc"); !ok {
+		t.Error("expected pgc to match PogChamp")
+	}
+	if _, ok := hasherino.FuzzyScore("PogChamp", "cg"); ok {
+		t.Error("expected cg not to match PogChamp, the characters are out of order")
+	}
+	prefix, _ := hasherino.FuzzyScore("KEKW", "kek")
+	scattered, _ := hasherino.FuzzyScore("OMEGALUL", "oml")
+	if prefix <= scattered {
+		t.Errorf("expected consecutive prefix match to score higher, got %d <= %d", prefix, scattered)
+	}
+}
+
+func TestRankCompletions(t *testing.T) {
+	candidates := []string{"catJAM", "Clap", "peepoClap", "EZ", "clapclap"}
+	usage := map[string]int{"clapclap": 3}
+
+	got := hasherino.RankCompletions("clap", candidates, usage, 10)
+	expected := []string{"clapclap", "Clap", "peepoClap"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	got = hasherino.RankCompletions("cj", candidates, usage, 10)
+	if !reflect.DeepEqual(got, []string{"catJAM"}) {
+		t.Errorf("expected fuzzy match catJAM, got %v", got)
+	}
+
+	if got := hasherino.RankCompletions("c", candidates, usage, 2); len(got) != 2 {
+		t.Errorf("expected results to be limited to 2, got %v", got)
+	}
+}
@@ -1,34 +1,65 @@
 package hasherino
 
 import (
-	"encoding/json"
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
 	"errors"
-	"io"
 	"log"
 	"os"
-	"path"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Controlls everything in the app. Called by UI code, making it UI library agnostic.
 type HasherinoController struct {
-	appId       string
-	selectedTab string
-	callbackMap map[string]func(ChatMessage)
-	twitchOAuth *TwitchOAuth
-	readWS      *TwitchChatWebsocket
-	writeWS     *TwitchChatWebsocket
-	memDB       *gorm.DB
-	permDB      *gorm.DB
+	appId          string
+	selectedTab    string
+	callbacksMutex sync.RWMutex
+	callbackMap    map[string]func(ChatMessage) // by channel, see SetChatCallback
+	twitchOAuth    *TwitchOAuth
+	readWS         *TwitchChatWebsocket
+	writeWS        *TwitchChatWebsocket
+	memDB          *gorm.DB
+	permDB         *gorm.DB
+	chatters       *BatchWriter[seenChatter]
+
+	eventSub              atomic.Pointer[EventSubClient]
+	eventSubMutex         sync.Mutex // held while eventsub (re)starts, guards eventSubCancel
+	eventSubCancel        context.CancelFunc
+	whisperCallback       func(*Whisper)
+	channelEventMutex     sync.Mutex
+	channelEventCallbacks map[string]func(ChannelEvent)
+	highlights            atomic.Pointer[HighlightEngine]
+	filter                atomic.Pointer[MessageFilter]
+	chatLogger            *ChatLogger
+	messageIndex          *MessageIndex
+	indexer               *BatchWriter[*ChatMessage] // adds to messageIndex
+	mentionCallback       func(ChatMessage)
+	lastReceivedMutex     sync.Mutex
+	lastReceived          map[string]time.Time // when the newest message of each channel was sent
+	handledIdsMutex       sync.Mutex
+	handledIds            map[string]struct{} // ids of the latest chat messages, see firstHandled
+	handledIdsOrder       []string
+	badgeCacheMutex       sync.Mutex
+	badgeCache            map[[2]string][]*ChatBadge // badge versions by set id and room id, see badgeCandidates
+	revokedMutex          sync.Mutex
+	revoked               map[string][]string // revocation notices shown in each channel since eventsub started
+	settingsMutex         sync.Mutex
+	settingsCallbacks     map[string]func(*AppSettings) // by channel, see SetSettingsCallback
 }
 
-func (hc *HasherinoController) New(callbackMap map[string]func(ChatMessage)) (*HasherinoController, error) {
+func (hc *HasherinoController) New() (*HasherinoController, error) {
 	writeWS := &TwitchChatWebsocket{}
 	readWS := &TwitchChatWebsocket{}
 
@@ -41,30 +72,70 @@ func (hc *HasherinoController) New(callbackMap map[string]func(ChatMessage)) (*H
 	if err != nil {
 		return nil, err
 	}
-	memDB.AutoMigrate(&TempTab{}, &ChatUser{}, &Emote{}, &ChatUserTempTab{})
+	memDB.SetupJoinTable(&TempTab{}, "ChatUsers", &ChatUserTempTab{})
+	memDB.SetupJoinTable(&ChatUser{}, "TempTabs", &ChatUserTempTab{})
+	memDB.AutoMigrate(&TempTab{}, &ChatUser{}, &Emote{}, &ChatUserTempTab{}, &Emoji{}, &ChatBadge{})
 
 	permDB, err := gorm.Open(sqlite.Open(filepath.Join(dataFolder, "gorm.db")), &gorm.Config{})
 	if err != nil {
 		return nil, err
 	}
-	permDB.AutoMigrate(&Account{}, &Tab{}, &AppSettings{})
+	seedHighlights := !permDB.Migrator().HasTable(&HighlightRule{})
+	// Counts from before they were kept per channel can't be told apart, they start over
+	if permDB.Migrator().HasTable(&CompletionUsage{}) && !permDB.Migrator().HasColumn(&CompletionUsage{}, "Channel") {
+		permDB.Migrator().DropTable(&CompletionUsage{})
+	}
+	// Twemoji is on by default, settings saved before it existed would get it off
+	migrateTwemoji := permDB.Migrator().HasTable(&AppSettings{}) &&
+		!permDB.Migrator().HasColumn(&AppSettings{}, "TwemojiRendering")
+	permDB.AutoMigrate(&Account{}, &Tab{}, &AppSettings{}, &CompletionUsage{}, &Whisper{}, &HighlightRule{},
+		&IgnoredUser{}, &IgnoredPhrase{}, &Page{}, &PopOut{})
+	if seedHighlights {
+		permDB.Create(&HighlightRule{Kind: HighlightMention, Color: "#7f3f49", Sound: true, Enabled: true})
+	}
+	if migrateTwemoji {
+		permDB.Model(&AppSettings{}).Where("twemoji_rendering IS NOT ?", true).Update("twemoji_rendering", true)
+	}
+	if err := MigrateTabsToPages(permDB); err != nil {
+		return nil, err
+	}
+
+	messageIndex, err := OpenMessageIndex(filepath.Join(dataFolder, "search.db"))
+	if err != nil {
+		return nil, err
+	}
 
 	c := &HasherinoController{
 		appId:       "hvmj7blkwy2gw3xf820n47i85g4sub",
-		callbackMap: callbackMap,
+		callbackMap: make(map[string]func(ChatMessage)),
 		twitchOAuth: NewTwitchOAuth(),
 		readWS:      readWS,
 		writeWS:     writeWS,
 		memDB:       memDB,
 		permDB:      permDB,
+
+		chatLogger:            NewChatLogger(filepath.Join(dataFolder, "logs")),
+		messageIndex:          messageIndex,
+		channelEventCallbacks: make(map[string]func(ChannelEvent)),
+		lastReceived:          make(map[string]time.Time),
+		handledIds:            make(map[string]struct{}),
+		badgeCache:            make(map[[2]string][]*ChatBadge),
+		revoked:               make(map[string][]string),
+		settingsCallbacks:     make(map[string]func(*AppSettings)),
 	}
 	settings := &AppSettings{}
 	result := permDB.Take(settings)
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		result = permDB.Create(&AppSettings{ChatMessageLimit: 100, ChatHistory: false})
+		result = permDB.Create(&AppSettings{ChatMessageLimit: 100, ChatHistory: false, TwemojiRendering: true})
 	} else if result.Error != nil {
 		return nil, err
 	}
+	c.chatters = NewBatchWriter("chatters", 100, time.Second, c.writeChatters)
+	c.indexer = NewBatchWriter("search index", 100, time.Second, messageIndex.AddAll)
+	c.loadHighlights()
+	c.loadFilter()
+	c.configureLogger()
+	go c.pruneHistory()
 	go c.twitchOAuth.ListenForOAuthRedirect(c)
 	return c, nil
 }
@@ -163,13 +234,11 @@ func (hc *HasherinoController) OpenOAuthPage() {
 	hc.twitchOAuth.OpenOAuthPage(hc.appId)
 }
 
-func (hc *HasherinoController) AddTab(channel string) error {
+// Joins channel and saves its tab, returning the channel's login, which is how the tab is known
+// from then on whatever the case channel was typed in
+func (hc *HasherinoController) AddTab(channel string) (string, error) {
+	login := ""
 	err := hc.permDB.Transaction(func(tx *gorm.DB) error {
-		_, exists := hc.readWS.channels[channel]
-		if exists {
-			return errors.New("already joined channel " + channel)
-		}
-
 		activeAccount := &Account{}
 		result := hc.permDB.Take(&activeAccount, "Active = ?", true)
 		if result.Error != nil {
@@ -181,20 +250,27 @@ func (hc *HasherinoController) AddTab(channel string) error {
 		if err != nil || len(users.Data) != 1 {
 			return errors.New("failed to obtain channel's id and login")
 		}
+		login = users.Data[0].Login
+		if hc.readWS.HasChannel(login) {
+			return errors.New("already joined channel " + login)
+		}
 
 		tab := &Tab{
 			Id:          users.Data[0].ID,
-			Login:       users.Data[0].Login,
+			Login:       login,
 			DisplayName: users.Data[0].DisplayName,
-			Selected:    false,
 		}
 
 		result = tx.Create(&tab)
+		if result.Error != nil {
+			return result.Error
+		}
+		hc.subscribeChannel(tab.Id, activeAccount.Id)
 
-		err = hc.readWS.Join(channel)
+		err = hc.readWS.Join(login)
 		if err != nil {
-			log.Printf("failed to join channel %s: %s", channel, err)
-			return errors.New("failed to join channel " + channel)
+			log.Printf("failed to join channel %s: %s", login, err)
+			return errors.New("failed to join channel " + login)
 		}
 
 		err = hc.AddTempTabs(&[]string{users.Data[0].ID})
@@ -204,41 +280,7 @@ func (hc *HasherinoController) AddTab(channel string) error {
 
 		return nil
 	})
-	return err
-}
-
-type EmojiJsonMap map[string]struct {
-	Name            string `json:"name"`
-	Slug            string `json:"slug"`
-	Group           string `json:"group"`
-	EmojiVersion    string `json:"emoji_version"`
-	UnicodeVersion  string `json:"unicode_version"`
-	SkinToneSupport bool   `json:"skin_tone_support"`
-}
-
-var cachedEmojiJson *EmojiJsonMap
-
-func GetEmojiJSONMap() (*EmojiJsonMap, error) {
-	if cachedEmojiJson != nil {
-		return cachedEmojiJson, nil
-	}
-
-	file, err := os.Open(path.Join("..", "data-by-emoji.json"))
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-	bytes, err := io.ReadAll(file)
-	if err != nil {
-		return nil, err
-	}
-	var emojiJson EmojiJsonMap
-	err = json.Unmarshal(bytes, &emojiJson)
-	if err != nil {
-		return nil, err
-	}
-	cachedEmojiJson = &emojiJson
-	return &emojiJson, nil
+	return login, err
 }
 
 func (hc *HasherinoController) AddTempTabs(channelIds *[]string) error {
@@ -252,11 +294,6 @@ func (hc *HasherinoController) AddTempTabs(channelIds *[]string) error {
 				} else {
 					emoteObjs := []Emote{}
 					for _, emote := range emotes.Data.EmoteSet.Emotes {
-						tempFile, err := os.CreateTemp("", "")
-						if err != nil {
-							log.Printf("Failed to create temp file %s for 7tv emote %s", err, emote.Name)
-							continue
-						}
 						emoteObjs = append(emoteObjs, Emote{
 							Id:        emote.ID,
 							Source:    SevenTV,
@@ -265,9 +302,7 @@ func (hc *HasherinoController) AddTempTabs(channelIds *[]string) error {
 							ChannelID: nil,
 							OwnerID:   "",
 							Owner:     nil,
-							TempFile:  tempFile.Name(),
 						})
-						tempFile.Close()
 					}
 					log.Println("Loaded " + strconv.Itoa(len(emoteObjs)) + " 7tv global emotes")
 					result := tx.Create(&emoteObjs)
@@ -277,12 +312,22 @@ func (hc *HasherinoController) AddTempTabs(channelIds *[]string) error {
 				}
 			}
 			// Insert emoji
-			// emojiData, err := GetEmojiJSONMap()
-			// if err != nil {
-			// 	log.Println("failed to load emoji data: " + err.Error())
-			// } else {
-			//
-			// }
+			var emojiCount int64
+			result := tx.Model(&Emoji{}).Count(&emojiCount)
+			if result.Error != nil {
+				return result.Error
+			}
+			if emojiCount == 0 {
+				emoji, err := GetEmojiList()
+				if err != nil {
+					log.Println("failed to load emoji data: " + err.Error())
+				} else {
+					result = tx.CreateInBatches(emoji, 500)
+					if result.Error != nil {
+						log.Printf("Failed to save emoji: %s", result.Error)
+					}
+				}
+			}
 
 			// Insert channel 7tv emotes
 			stvUsers := make(map[string]*STVUserJson)
@@ -318,11 +363,6 @@ func (hc *HasherinoController) AddTempTabs(channelIds *[]string) error {
 							continue
 						}
 						for _, emote := range emoteSet.Emotes {
-							tempFile, err := os.CreateTemp("", "")
-							if err != nil {
-								log.Printf("Failed to create temp file %s for 7tv emote %s", err, emote.Data.Name)
-								continue
-							}
 							e := Emote{
 								Id:        emote.Data.ID,
 								Source:    SevenTV,
@@ -331,9 +371,7 @@ func (hc *HasherinoController) AddTempTabs(channelIds *[]string) error {
 								ChannelID: &channelId,
 								OwnerID:   "",
 								Owner:     nil,
-								TempFile:  tempFile.Name(),
 							}
-							tempFile.Close()
 							emoteObjs = append(emoteObjs, e)
 						}
 					}
@@ -351,6 +389,7 @@ func (hc *HasherinoController) AddTempTabs(channelIds *[]string) error {
 		if err != nil {
 			log.Printf("Failed to add temp tabs: %s", err)
 		}
+		hc.loadBadges(*channelIds)
 	}(channelIds)
 
 	err := hc.memDB.Transaction(func(tx *gorm.DB) error {
@@ -371,16 +410,140 @@ func (hc *HasherinoController) AddTempTabs(channelIds *[]string) error {
 	return err
 }
 
-func (hc *HasherinoController) GetEmotes(search string) ([]*Emote, error) {
-	emotes := []*Emote{}
-	err := hc.memDB.Transaction(func(tx *gorm.DB) error {
-		tab := &Tab{}
-		result := hc.permDB.Take(&tab, "Selected = ?", true)
+// Saves the global badges, if they weren't loaded yet, and the badges of every channel
+func (hc *HasherinoController) loadBadges(channelIds []string) {
+	activeAccount, err := hc.GetActiveAccount()
+	if err != nil {
+		log.Println("No active account, badges won't be loaded")
+		return
+	}
+	helix := NewHelix(hc.appId)
+
+	var globalCount int64
+	result := hc.memDB.Model(&ChatBadge{}).Where("channel_id = ?", "").Count(&globalCount)
+	if result.Error != nil {
+		log.Printf("Failed to count global badges: %s", result.Error)
+		return
+	}
+	if globalCount == 0 {
+		channelIds = append([]string{""}, channelIds...)
+	}
+
+	for _, channelId := range channelIds {
+		sets, err := helix.GetChatBadges(activeAccount.Token, channelId)
+		if err != nil {
+			log.Printf("Failed to load badges for channel '%s': %s", channelId, err)
+			continue
+		}
+		badges := []ChatBadge{}
+		for _, set := range sets {
+			for _, version := range set.Versions {
+				badges = append(badges, ChatBadge{
+					SetId:     set.SetID,
+					Version:   version.ID,
+					ChannelID: channelId,
+					Title:     version.Title,
+					ImageUrl:  version.ImageURL2X,
+				})
+			}
+		}
+		log.Println("Loaded " + strconv.Itoa(len(badges)) + " badges for channel '" + channelId + "'")
+		if len(badges) == 0 {
+			continue
+		}
+		result := hc.memDB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&badges)
 		if result.Error != nil {
-			return result.Error
+			log.Printf("Failed to save badges: %s", result.Error)
+		}
+	}
+	hc.badgeCacheMutex.Lock()
+	clear(hc.badgeCache)
+	hc.badgeCacheMutex.Unlock()
+}
+
+// Versions of the badge set in the room and the global ones, channel badges first. Cached
+// until the badges are loaded again, since every message looks up its author's.
+func (hc *HasherinoController) badgeCandidates(setId string, roomId string) ([]*ChatBadge, error) {
+	key := [2]string{setId, roomId}
+	hc.badgeCacheMutex.Lock()
+	candidates, ok := hc.badgeCache[key]
+	hc.badgeCacheMutex.Unlock()
+	if ok {
+		return candidates, nil
+	}
+
+	candidates = []*ChatBadge{}
+	result := hc.memDB.
+		Where("set_id = ? AND (channel_id = ? OR channel_id = ?)", setId, roomId, "").
+		Order("channel_id DESC").
+		Find(&candidates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	hc.badgeCacheMutex.Lock()
+	hc.badgeCache[key] = candidates
+	hc.badgeCacheMutex.Unlock()
+	return candidates, nil
+}
+
+// Returns the badges to show before the author of msg, channel badges taking precedence over
+// global ones. Subscriber badges are picked from the months in badge-info.
+func (hc *HasherinoController) GetMessageBadges(msg *ChatMessage) []*ChatBadge {
+	roomId := msg.Tags["room-id"]
+	months := 0
+	for _, info := range ParseBadges(msg.Tags["badge-info"]) {
+		if info.Name == "subscriber" {
+			months, _ = strconv.Atoi(info.Version)
+		}
+	}
+
+	badges := []*ChatBadge{}
+	for _, tagBadge := range ParseBadges(msg.Tags["badges"]) {
+		candidates, err := hc.badgeCandidates(tagBadge.Name, roomId)
+		if err != nil {
+			log.Printf("Failed to find badge %s: %s", tagBadge.Name, err)
+			continue
+		}
+
+		version := tagBadge.Version
+		if tagBadge.Name == "subscriber" {
+			available := []string{}
+			for _, candidate := range candidates {
+				if candidate.ChannelID != "" {
+					available = append(available, candidate.Version)
+				}
+			}
+			if resolved, ok := SubscriberBadgeVersion(available, tagBadge.Version, months); ok {
+				version = resolved
+			}
+		}
+		// Channel badges are ordered first
+		for _, candidate := range candidates {
+			if candidate.Version == version {
+				badges = append(badges, candidate)
+				break
+			}
 		}
+	}
+	return badges
+}
+
+// Emotes usable in channel whose name contains search
+func (hc *HasherinoController) GetEmotes(channel string, search string) ([]*Emote, error) {
+	tab := &Tab{}
+	result := hc.permDB.Take(&tab, "Login = ?", channel)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return hc.getTabEmotes(tab, search)
+}
+
+// Emotes usable in tab by the active account
+func (hc *HasherinoController) getTabEmotes(tab *Tab, search string) ([]*Emote, error) {
+	emotes := []*Emote{}
+	err := hc.memDB.Transaction(func(tx *gorm.DB) error {
 		activeAccount := &Account{}
-		result = hc.permDB.Take(&activeAccount, "Active = ?", true)
+		result := hc.permDB.Take(&activeAccount, "Active = ?", true)
 		if result.Error != nil {
 			return result.Error
 		}
@@ -399,149 +562,1284 @@ func (hc *HasherinoController) GetEmotes(search string) ([]*Emote, error) {
 	return emotes, nil
 }
 
-func (hc *HasherinoController) RemoveTab(id string) error {
-	err := hc.permDB.Transaction(func(tx *gorm.DB) error {
-		tab := &Tab{}
-		result := tx.Take(&tab, "Id = ?", id)
-		if result.Error != nil {
-			return errors.New("tab not found for id " + id)
-		}
+// Returns up to limit tab completions for word in channel, recent chatters when it starts with @
+// and emotes otherwise. Words sent more often in channel rank higher.
+func (hc *HasherinoController) GetCompletions(channel string, word string, limit int) ([]string, error) {
+	tab := &Tab{}
+	result := hc.permDB.Take(&tab, "Login = ?", channel)
+	if result.Error != nil {
+		return nil, result.Error
+	}
 
-		result = tx.Delete(&tab)
-		if result.Error != nil {
-			return result.Error
+	candidates := []string{}
+	if strings.HasPrefix(word, "@") {
+		chatters, err := hc.GetChatters(channel, "")
+		if err != nil {
+			return nil, err
 		}
-
-		result = hc.memDB.Delete(&TempTab{}, "Id = ?", id)
-		if result.Error != nil {
-			return result.Error
+		for _, chatter := range chatters {
+			candidates = append(candidates, "@"+chatter.Name())
 		}
-
-		err := hc.readWS.Part(tab.Login)
+	} else {
+		emotes, err := hc.getTabEmotes(tab, "")
 		if err != nil {
-			return errors.New("failed to part channel" + tab.Login)
+			return nil, err
 		}
+		for _, emote := range emotes {
+			candidates = append(candidates, emote.Name)
+		}
+	}
+
+	usages := []*CompletionUsage{}
+	result = hc.permDB.Where("channel = ? AND word IN ?", channel, candidates).Find(&usages)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	usage := make(map[string]int)
+	for _, u := range usages {
+		usage[u.Word] = u.Uses
+	}
+
+	return RankCompletions(word, candidates, usage, limit), nil
+}
+
+// Counts the emotes and mentions in a sent message so they rank higher in tab completion
+func (hc *HasherinoController) RecordCompletionUsage(channel string, message string) error {
+	tab := &Tab{}
+	result := hc.permDB.Take(&tab, "Login = ?", channel)
+	if result.Error != nil {
+		return result.Error
+	}
+	emotes, err := hc.getTabEmotes(tab, "")
+	if err != nil {
+		return err
+	}
+	emoteNames := make(map[string]struct{})
+	for _, emote := range emotes {
+		emoteNames[emote.Name] = struct{}{}
+	}
 
+	return hc.permDB.Transaction(func(tx *gorm.DB) error {
+		for _, word := range strings.Fields(message) {
+			if _, isEmote := emoteNames[word]; !isEmote && !strings.HasPrefix(word, "@") {
+				continue
+			}
+			result := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "channel"}, {Name: "word"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{"uses": gorm.Expr("uses + 1")}),
+			}).Create(&CompletionUsage{Channel: channel, Word: word, Uses: 1})
+			if result.Error != nil {
+				return result.Error
+			}
+		}
 		return nil
 	})
-	return err
 }
 
-func (hc *HasherinoController) GetTabs() ([]*Tab, error) {
-	tabs := []*Tab{}
-	result := hc.permDB.Find(&tabs)
-	return tabs, result.Error
+// A chatter seen in a message, waiting to be written by the chatters BatchWriter
+type seenChatter struct {
+	user    ChatUser
+	chatter ChatUserTempTab
 }
 
-func (hc *HasherinoController) SetSelectedTab(login string) error {
-	return hc.permDB.Transaction(func(tx *gorm.DB) error {
-		selectedTabs := []*Tab{}
-		result := tx.Find(&selectedTabs, "Selected = ?", true)
-		if result.Error != nil {
-			return result.Error
-		}
-		if len(selectedTabs) > 0 {
-			for _, tab := range selectedTabs {
-				tab.Selected = false
-				result = tx.Save(&tab)
-				if result.Error != nil {
-					return result.Error
-				}
+// Remembers who chatted in each channel, with the badges they had there. Only queues the
+// chatter, writeChatters saves them in batches.
+func (hc *HasherinoController) recordChatter(msg *ChatMessage) {
+	userId, roomId := msg.Tags["user-id"], msg.Tags["room-id"]
+	if msg.Command != "PRIVMSG" || userId == "" || roomId == "" {
+		return
+	}
+	hc.chatters.Add(seenChatter{
+		user: ChatUser{
+			Id:          userId,
+			Login:       msg.Author,
+			DisplayName: msg.Tags["display-name"],
+		},
+		chatter: ChatUserTempTab{
+			ChatUserId: userId,
+			TempTabId:  roomId,
+			Badges:     msg.Tags["badges"],
+			LastSeen:   time.Now(),
+		},
+	})
+}
+
+// Saves a batch of chatters in one transaction, keeping the latest message of each
+func (hc *HasherinoController) writeChatters(seen []seenChatter) error {
+	users := map[string]ChatUser{}
+	chatters := map[[2]string]ChatUserTempTab{}
+	for _, s := range seen {
+		users[s.user.Id] = s.user
+		chatters[[2]string{s.chatter.ChatUserId, s.chatter.TempTabId}] = s.chatter
+	}
+	return hc.memDB.Transaction(func(tx *gorm.DB) error {
+		for _, user := range users {
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&user).Error; err != nil {
+				return err
 			}
 		}
-		tab := &Tab{}
-		result = hc.permDB.Take(&tab, "Login = ?", login)
-		if result.Error != nil {
-			return result.Error
-		}
-		tab.Selected = true
-		result = tx.Save(&tab)
-		if result.Error != nil {
-			return result.Error
+		for _, chatter := range chatters {
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&chatter).Error; err != nil {
+				return err
+			}
 		}
 		return nil
 	})
 }
 
-func (hc *HasherinoController) GetSelectedTab() (*Tab, error) {
+// Returns the known chatters of channel whose name contains search, most recently seen first
+func (hc *HasherinoController) GetChatters(channel string, search string) ([]*Chatter, error) {
 	tab := &Tab{}
-	result := hc.permDB.Take(&tab, "Selected = ?", true)
-	return tab, result.Error
+	result := hc.permDB.Take(&tab, "Login = ?", channel)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	chatters := []*Chatter{}
+	search = "%" + search + "%"
+	result = hc.memDB.Table("chat_users").
+		Select("chat_users.id, chat_users.login, chat_users.display_name, "+
+			"chat_user_temp_tab.badges, chat_user_temp_tab.last_seen").
+		Joins("JOIN chat_user_temp_tab ON chat_user_temp_tab.chat_user_id = chat_users.id").
+		Where("chat_user_temp_tab.temp_tab_id = ?", tab.Id).
+		Where("chat_users.login LIKE ? OR chat_users.display_name LIKE ?", search, search).
+		Order("chat_user_temp_tab.last_seen DESC, chat_users.login").
+		Scan(&chatters)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return chatters, nil
 }
 
-func (hc *HasherinoController) Listen() error {
-	activeAccount, err := hc.GetActiveAccount()
-	if err == nil {
-		if hc.writeWS == nil || hc.writeWS.State == Disconnected {
-			hc.writeWS, err = hc.writeWS.New("oauth:"+activeAccount.Token, activeAccount.Login)
-			if err != nil {
-				return err
+// Loads everyone connected to channel's chat from helix, only allowed for its moderators.
+// Chatters that never sent a message keep an empty last seen time.
+func (hc *HasherinoController) FetchChatters(channel string) error {
+	tab, activeAccount, err := hc.getTabAndAccount(channel)
+	if err != nil {
+		return err
+	}
+
+	helix := NewHelix(hc.appId)
+	chatters, err := helix.GetChatters(activeAccount.Token, tab.Id, activeAccount.Id)
+	if err != nil {
+		return err
+	}
+
+	return hc.memDB.Transaction(func(tx *gorm.DB) error {
+		for _, chatter := range chatters {
+			user := &ChatUser{
+				Id:          chatter.UserID,
+				Login:       chatter.UserLogin,
+				DisplayName: chatter.UserName,
 			}
-			err = hc.writeWS.Connect()
-			if err != nil {
-				return err
+			result := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(user)
+			if result.Error != nil {
+				return result.Error
+			}
+			result = tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&ChatUserTempTab{ChatUserId: chatter.UserID, TempTabId: tab.Id})
+			if result.Error != nil {
+				return result.Error
 			}
 		}
+		log.Println("Loaded " + strconv.Itoa(len(chatters)) + " chatters for " + channel)
+		return nil
+	})
+}
+
+// Everything shown in a user card
+type UserCard struct {
+	User        HelixUser
+	Avatar      *ProfileImage
+	Badges      string     // badges in the channel, empty if they weren't seen chatting
+	FollowKnown bool       // only moderators can see who follows a channel
+	FollowedAt  *time.Time // nil if the user doesn't follow the channel
+}
+
+func (hc *HasherinoController) GetUserCard(channel string, login string) (*UserCard, error) {
+	tab, activeAccount, err := hc.getTabAndAccount(channel)
+	if err != nil {
+		return nil, err
 	}
-	if hc.readWS == nil || hc.readWS.State == Disconnected {
-		hc.readWS, err = hc.readWS.New("SCHMOOPIIE", "justinfan71097")
-		if err != nil {
-			return err
-		}
-		err = hc.readWS.Connect()
-		if err != nil {
-			return err
-		}
+
+	helix := NewHelix(hc.appId)
+	users, err := helix.GetUsers(activeAccount.Token, []string{login})
+	if err != nil {
+		return nil, err
+	}
+	if len(users.Data) != 1 {
+		return nil, errors.New("user " + login + " not found")
+	}
+	user := users.Data[0]
+	card := &UserCard{
+		User:   user,
+		Avatar: &ProfileImage{UserId: user.ID, Url: user.ProfileImageURL},
 	}
 
-	callbackWrapper := func(message string) {
-		msg, err := ParseMessage(message)
-		if err != nil {
-			log.Printf("Failed to parse message: %s", err)
-			return
-		}
+	chatter := &ChatUserTempTab{}
+	result := hc.memDB.Take(chatter, "chat_user_id = ? AND temp_tab_id = ?", user.ID, tab.Id)
+	if result.Error == nil {
+		card.Badges = chatter.Badges
+	}
 
-		callback, ok := hc.callbackMap[msg.Channel]
-		if !ok {
-			log.Printf("No callback for channel %s.", msg.Channel)
-			return
-		}
-		callback(*msg)
+	followedAt, err := helix.GetFollowedAt(activeAccount.Token, tab.Id, user.ID)
+	if err != nil {
+		log.Printf("Failed to get follow age of %s in %s: %s", login, channel, err)
+	} else {
+		card.FollowKnown = true
+		card.FollowedAt = followedAt
 	}
+	return card, nil
+}
 
-	for channel := range hc.callbackMap {
-		_, joinedChannel := hc.writeWS.channels[channel]
-		if !joinedChannel {
-			hc.readWS.Join(channel)
-		}
+// Bans userId from channel, only for duration if it's not 0
+func (hc *HasherinoController) BanUser(channel string, userId string, duration time.Duration, reason string) error {
+	tab, activeAccount, err := hc.getTabAndAccount(channel)
+	if err != nil {
+		return err
 	}
+	return NewHelix(hc.appId).BanUser(activeAccount.Token, tab.Id, activeAccount.Id, userId, duration, reason)
+}
 
-	go hc.readWS.Listen(callbackWrapper)
-	return nil
+func (hc *HasherinoController) UnbanUser(channel string, userId string) error {
+	tab, activeAccount, err := hc.getTabAndAccount(channel)
+	if err != nil {
+		return err
+	}
+	return NewHelix(hc.appId).UnbanUser(activeAccount.Token, tab.Id, activeAccount.Id, userId)
 }
 
-func (hc *HasherinoController) IsChannelJoined(channel string) bool {
-	if hc.readWS == nil || hc.readWS.State == Disconnected {
-		return false
+// Allows or denies a message AutoMod held in channel
+func (hc *HasherinoController) ResolveAutoModMessage(channel string, messageId string, allow bool) error {
+	_, activeAccount, err := hc.getTabAndAccount(channel)
+	if err != nil {
+		return err
 	}
+	return NewHelix(hc.appId).ManageHeldAutoModMessage(activeAccount.Token, activeAccount.Id, messageId, allow)
+}
 
-	_, joinedChannel := hc.readWS.channels[channel]
-	return joinedChannel
+// Blocks the user on twitch and ignores their messages
+func (hc *HasherinoController) BlockUser(userId string, login string) error {
+	activeAccount, err := hc.GetActiveAccount()
+	if err != nil {
+		return errors.New("no active account")
+	}
+	if err := NewHelix(hc.appId).BlockUser(activeAccount.Token, userId); err != nil {
+		return err
+	}
+	return hc.saveIgnoredUser(&IgnoredUser{Login: login, UserId: userId, Synced: true})
 }
 
-func (hc *HasherinoController) SendMessage(channel string, message string) error {
-	err := hc.writeWS.Send(channel, message)
-	return err
+func (hc *HasherinoController) saveIgnoredUser(user *IgnoredUser) error {
+	result := hc.permDB.Save(user)
+	if result.Error != nil {
+		return result.Error
+	}
+	hc.loadFilter()
+	return nil
 }
 
-func (hc *HasherinoController) GetSettings() (*AppSettings, error) {
-	appSettings := &AppSettings{}
-	result := hc.permDB.Take(appSettings)
-	return appSettings, result.Error
+func (hc *HasherinoController) GetIgnoredUsers() ([]*IgnoredUser, error) {
+	users := []*IgnoredUser{}
+	result := hc.permDB.Order("login").Find(&users)
+	return users, result.Error
 }
 
-func (hc *HasherinoController) SetSettings(appSettings *AppSettings) error {
-	return hc.permDB.Save(appSettings).Error
+// Hides login's messages, also blocking them on twitch if block is set
+func (hc *HasherinoController) IgnoreUser(login string, block bool) error {
+	login = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(login), "@"))
+	if login == "" {
+		return errors.New("no user to ignore")
+	}
+	if !block {
+		return hc.saveIgnoredUser(&IgnoredUser{Login: login})
+	}
+	activeAccount, err := hc.GetActiveAccount()
+	if err != nil {
+		return errors.New("no active account")
+	}
+	users, err := NewHelix(hc.appId).GetUsers(activeAccount.Token, []string{login})
+	if err != nil || len(users.Data) != 1 {
+		return errors.New("user " + login + " not found")
+	}
+	return hc.BlockUser(users.Data[0].ID, users.Data[0].Login)
+}
+
+// Shows login's messages again, unblocking them on twitch if the ignore came from a block
+func (hc *HasherinoController) UnignoreUser(login string) error {
+	user := &IgnoredUser{}
+	result := hc.permDB.Take(user, "login = ?", login)
+	if result.Error != nil {
+		return result.Error
+	}
+	if user.Synced {
+		activeAccount, err := hc.GetActiveAccount()
+		if err != nil {
+			return errors.New("no active account")
+		}
+		if err := NewHelix(hc.appId).UnblockUser(activeAccount.Token, user.UserId); err != nil {
+			return err
+		}
+	}
+	result = hc.permDB.Delete(user)
+	if result.Error != nil {
+		return result.Error
+	}
+	hc.loadFilter()
+	return nil
+}
+
+// Replaces the synced ignored users with the active account's twitch blocks
+func (hc *HasherinoController) SyncBlockedUsers() error {
+	activeAccount, err := hc.GetActiveAccount()
+	if err != nil {
+		return errors.New("no active account")
+	}
+	blocked, err := NewHelix(hc.appId).GetBlockedUsers(activeAccount.Token, activeAccount.Id)
+	if err != nil {
+		return err
+	}
+	err = hc.permDB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&IgnoredUser{}, "synced = ?", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		for _, user := range blocked {
+			result = tx.Save(&IgnoredUser{Login: user.UserLogin, UserId: user.UserID, Synced: true})
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	hc.loadFilter()
+	return nil
+}
+
+func (hc *HasherinoController) GetIgnoredPhrases() ([]*IgnoredPhrase, error) {
+	phrases := []*IgnoredPhrase{}
+	result := hc.permDB.Order("id").Find(&phrases)
+	return phrases, result.Error
+}
+
+func (hc *HasherinoController) SaveIgnoredPhrase(phrase *IgnoredPhrase) error {
+	if strings.TrimSpace(phrase.Pattern) == "" {
+		return errors.New("ignored phrases need a pattern")
+	}
+	if _, err := ignoredPhraseRegex(phrase); err != nil {
+		return err
+	}
+	result := hc.permDB.Save(phrase)
+	if result.Error != nil {
+		return result.Error
+	}
+	hc.loadFilter()
+	return nil
+}
+
+func (hc *HasherinoController) DeleteIgnoredPhrase(id uint) error {
+	result := hc.permDB.Delete(&IgnoredPhrase{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	hc.loadFilter()
+	return nil
+}
+
+// Sets which of channel's messages are shown, applied to the following messages
+func (hc *HasherinoController) SetTabFilters(channel string, onlySubscribers bool, onlyLinks bool, hideCommands bool) error {
+	result := hc.permDB.Model(&Tab{}).Where("login = ?", channel).Updates(map[string]any{
+		"only_subscribers": onlySubscribers,
+		"only_links":       onlyLinks,
+		"hide_commands":    hideCommands,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	hc.loadFilter()
+	return nil
+}
+
+func (hc *HasherinoController) GetTab(channel string) (*Tab, error) {
+	tab := &Tab{}
+	result := hc.permDB.Take(tab, "login = ?", channel)
+	return tab, result.Error
+}
+
+func (hc *HasherinoController) loadFilter() {
+	users, err := hc.GetIgnoredUsers()
+	if err != nil {
+		log.Println("failed to load ignored users:", err)
+		return
+	}
+	phrases, err := hc.GetIgnoredPhrases()
+	if err != nil {
+		log.Println("failed to load ignored phrases:", err)
+		return
+	}
+	tabs, err := hc.GetTabs()
+	if err != nil {
+		log.Println("failed to load tab filters:", err)
+		return
+	}
+	hc.filter.Store(NewMessageFilter(users, phrases, tabs))
+}
+
+// Applies the ignores and tab filters to msg and sets its highlight. Returns false if msg is hidden.
+func (hc *HasherinoController) ProcessMessage(msg *ChatMessage) bool {
+	if filter := hc.filter.Load(); filter != nil && !filter.Apply(msg) {
+		return false
+	}
+	msg.Highlight = hc.Highlight(msg)
+	return true
+}
+
+func (hc *HasherinoController) SendWhisper(login string, message string) error {
+	activeAccount, err := hc.GetActiveAccount()
+	if err != nil {
+		return errors.New("no active account")
+	}
+	helix := NewHelix(hc.appId)
+	users, err := helix.GetUsers(activeAccount.Token, []string{login})
+	if err != nil {
+		return err
+	}
+	if len(users.Data) != 1 {
+		return errors.New("user " + login + " not found")
+	}
+	user := users.Data[0]
+
+	err = helix.SendWhisper(activeAccount.Token, activeAccount.Id, user.ID, message)
+	if err != nil {
+		return err
+	}
+	return hc.saveWhisper(&Whisper{
+		AccountId:   activeAccount.Id,
+		UserId:      user.ID,
+		Login:       user.Login,
+		DisplayName: user.DisplayName,
+		Incoming:    false,
+		Text:        message,
+	})
+}
+
+// Called with every whisper sent or received, from any goroutine
+func (hc *HasherinoController) SetWhisperCallback(callback func(*Whisper)) {
+	hc.whisperCallback = callback
+}
+
+func (hc *HasherinoController) saveWhisper(whisper *Whisper) error {
+	result := hc.permDB.Create(whisper)
+	if result.Error != nil {
+		return result.Error
+	}
+	if hc.whisperCallback != nil {
+		hc.whisperCallback(whisper)
+	}
+	return nil
+}
+
+// Returns the users the active account whispered with, most recent first
+func (hc *HasherinoController) GetWhisperConversations() ([]*WhisperConversation, error) {
+	activeAccount, err := hc.GetActiveAccount()
+	if err != nil {
+		return nil, errors.New("no active account")
+	}
+	conversations := []*WhisperConversation{}
+	// sqlite takes the other columns from the row with the max id
+	result := hc.permDB.Model(&Whisper{}).
+		Select("user_id, login, display_name, MAX(id)").
+		Where("account_id = ?", activeAccount.Id).
+		Group("user_id").
+		Order("MAX(id) DESC").
+		Scan(&conversations)
+	return conversations, result.Error
+}
+
+// Returns the whispers between the active account and userId, oldest first
+func (hc *HasherinoController) GetWhispers(userId string) ([]*Whisper, error) {
+	activeAccount, err := hc.GetActiveAccount()
+	if err != nil {
+		return nil, errors.New("no active account")
+	}
+	whispers := []*Whisper{}
+	result := hc.permDB.Where("account_id = ? AND user_id = ?", activeAccount.Id, userId).Order("id").Find(&whispers)
+	return whispers, result.Error
+}
+
+// (Re)starts the eventsub connection for account, replacing the previous one
+func (hc *HasherinoController) startEventSub(account *Account) {
+	helix := NewHelix(hc.appId)
+	hc.runEventSub(eventSubUrl, account.Id, func(sessionId string, subscription EventSubSubscription) error {
+		return helix.CreateEventSubSubscription(
+			account.Token, subscription.Type, subscription.Version, subscription.Condition, sessionId)
+	})
+}
+
+// Connects to the eventsub server at url, creating the subscriptions of accountId with create.
+// Account switches may call it again while the previous client is still running, so restarts
+// are serialized.
+func (hc *HasherinoController) runEventSub(
+	url string,
+	accountId string,
+	create func(sessionId string, subscription EventSubSubscription) error,
+) {
+	hc.eventSubMutex.Lock()
+	defer hc.eventSubMutex.Unlock()
+	if hc.eventSubCancel != nil {
+		hc.eventSubCancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	hc.eventSubCancel = cancel
+	hc.revokedMutex.Lock()
+	clear(hc.revoked)
+	hc.revokedMutex.Unlock()
+
+	client := NewEventSubClient(url, create)
+	HandleEventSub(client, "user.whisper.message", hc.handleWhisperEvent)
+	HandleEventSub(client, "channel.channel_points_custom_reward_redemption.add", func(event *RedemptionEvent) {
+		hc.dispatchChannelEvent(event.BroadcasterUserLogin, ChannelEvent{Redemption: event})
+	})
+	for _, phase := range []string{"begin", "progress", "end"} {
+		HandleEventSub(client, "channel.poll."+phase, func(event *PollEvent) {
+			hc.dispatchChannelEvent(event.BroadcasterUserLogin, ChannelEvent{Poll: event})
+		})
+	}
+	for _, phase := range []string{"begin", "progress", "lock", "end"} {
+		HandleEventSub(client, "channel.prediction."+phase, func(event *PredictionEvent) {
+			hc.dispatchChannelEvent(event.BroadcasterUserLogin, ChannelEvent{Prediction: event})
+		})
+	}
+	for _, phase := range []string{"hold", "update"} {
+		HandleEventSub(client, "automod.message."+phase, func(event *AutoModMessageEvent) {
+			hc.dispatchChannelEvent(event.BroadcasterUserLogin, ChannelEvent{AutoMod: event})
+		})
+	}
+	client.Subscribe(EventSubSubscription{
+		Type:      "user.whisper.message",
+		Version:   "1",
+		Condition: map[string]string{"user_id": accountId},
+	})
+	client.OnRevocation = hc.handleRevocation
+	hc.eventSub.Store(client)
+
+	tabs, err := hc.GetTabs()
+	if err != nil {
+		log.Println("failed to load tabs for eventsub:", err)
+	}
+	for _, tab := range tabs {
+		hc.subscribeChannel(tab.Id, accountId)
+	}
+
+	go client.Run(ctx)
+}
+
+func (hc *HasherinoController) handleWhisperEvent(event *WhisperEvent) {
+	err := hc.saveWhisper(&Whisper{
+		AccountId:   event.ToUserId,
+		UserId:      event.FromUserId,
+		Login:       event.FromUserLogin,
+		DisplayName: event.FromUserName,
+		Incoming:    true,
+		Text:        event.Whisper.Text,
+	})
+	if err != nil {
+		log.Println("failed to save whisper:", err)
+	}
+}
+
+// Events of a channel the chat shows. Redemptions, polls and predictions need the
+// broadcaster's own token, so they're only subscribed to in the account's channel. AutoMod
+// is allowed for moderators too, other channels get those subscriptions refused.
+func channelSubscriptions(broadcasterId string, accountId string) []EventSubSubscription {
+	subscriptions := []EventSubSubscription{}
+	if broadcasterId == accountId {
+		condition := map[string]string{"broadcaster_user_id": broadcasterId}
+		types := []string{
+			"channel.channel_points_custom_reward_redemption.add",
+			"channel.poll.begin",
+			"channel.poll.progress",
+			"channel.poll.end",
+			"channel.prediction.begin",
+			"channel.prediction.progress",
+			"channel.prediction.lock",
+			"channel.prediction.end",
+		}
+		for _, eventType := range types {
+			subscriptions = append(subscriptions, EventSubSubscription{Type: eventType, Version: "1", Condition: condition})
+		}
+	}
+	moderatorCondition := map[string]string{"broadcaster_user_id": broadcasterId, "moderator_user_id": accountId}
+	for _, eventType := range []string{"automod.message.hold", "automod.message.update"} {
+		subscriptions = append(subscriptions, EventSubSubscription{Type: eventType, Version: "1", Condition: moderatorCondition})
+	}
+	return subscriptions
+}
+
+func (hc *HasherinoController) subscribeChannel(broadcasterId string, accountId string) {
+	eventSub := hc.eventSub.Load()
+	if eventSub == nil {
+		return
+	}
+	for _, subscription := range channelSubscriptions(broadcasterId, accountId) {
+		if err := eventSub.Subscribe(subscription); err != nil {
+			log.Printf("Failed to subscribe to %s: %s", subscription.Type, err)
+		}
+	}
+}
+
+// What stops showing up when a subscription of eventType is revoked
+func eventSubDescription(eventType string) string {
+	switch {
+	case strings.HasPrefix(eventType, "channel.channel_points_custom_reward_redemption."):
+		return "Channel point redemptions"
+	case strings.HasPrefix(eventType, "channel.poll."):
+		return "Polls"
+	case strings.HasPrefix(eventType, "channel.prediction."):
+		return "Predictions"
+	case strings.HasPrefix(eventType, "automod.message."):
+		return "AutoMod held messages"
+	case strings.HasPrefix(eventType, "user.whisper."):
+		return "Incoming whispers"
+	default:
+		return eventType
+	}
+}
+
+// Tells the chats what twitch stopped sending, once per kind of event. Channel events are
+// reported in their channel, the others in every channel.
+func (hc *HasherinoController) handleRevocation(subscription EventSubSubscription, status string) {
+	channels := []string{}
+	if broadcasterId, ok := subscription.Condition["broadcaster_user_id"]; ok {
+		tab := &Tab{}
+		if result := hc.permDB.Take(tab, "Id = ?", broadcasterId); result.Error == nil {
+			channels = append(channels, tab.Login)
+		}
+	} else if tabs, err := hc.GetTabs(); err == nil {
+		for _, tab := range tabs {
+			channels = append(channels, tab.Login)
+		}
+	}
+
+	text := eventSubDescription(subscription.Type) + " won't show anymore, twitch revoked them: " + status
+	hc.revokedMutex.Lock()
+	defer hc.revokedMutex.Unlock()
+	for _, channel := range channels {
+		if slices.Contains(hc.revoked[channel], text) {
+			continue
+		}
+		hc.revoked[channel] = append(hc.revoked[channel], text)
+		if callback, ok := hc.chatCallback(channel); ok {
+			callback(NewSystemMessage(channel, text))
+		}
+	}
+}
+
+// Called with the chat messages of channel, from the chat connection's goroutine. Nil removes it.
+func (hc *HasherinoController) SetChatCallback(channel string, callback func(ChatMessage)) {
+	hc.callbacksMutex.Lock()
+	defer hc.callbacksMutex.Unlock()
+	if callback == nil {
+		delete(hc.callbackMap, channel)
+		return
+	}
+	hc.callbackMap[channel] = callback
+}
+
+func (hc *HasherinoController) chatCallback(channel string) (func(ChatMessage), bool) {
+	hc.callbacksMutex.RLock()
+	defer hc.callbacksMutex.RUnlock()
+	callback, ok := hc.callbackMap[channel]
+	return callback, ok
+}
+
+// Channels with a chat callback
+func (hc *HasherinoController) chatChannels() []string {
+	hc.callbacksMutex.RLock()
+	defer hc.callbacksMutex.RUnlock()
+	channels := make([]string, 0, len(hc.callbackMap))
+	for channel := range hc.callbackMap {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// Called with the redemptions, polls and predictions of channel, from any goroutine
+func (hc *HasherinoController) SetChannelEventCallback(channel string, callback func(ChannelEvent)) {
+	hc.channelEventMutex.Lock()
+	defer hc.channelEventMutex.Unlock()
+	hc.channelEventCallbacks[channel] = callback
+}
+
+func (hc *HasherinoController) dispatchChannelEvent(channel string, event ChannelEvent) {
+	hc.channelEventMutex.Lock()
+	callback, ok := hc.channelEventCallbacks[channel]
+	hc.channelEventMutex.Unlock()
+	if !ok {
+		log.Printf("No event callback for channel %s.", channel)
+		return
+	}
+	callback(event)
+}
+
+func (hc *HasherinoController) loadHighlights() {
+	rules, err := hc.GetHighlightRules()
+	if err != nil {
+		log.Println("failed to load highlight rules:", err)
+		return
+	}
+	login := ""
+	if activeAccount, err := hc.GetActiveAccount(); err == nil {
+		login = activeAccount.Login
+	}
+	hc.highlights.Store(NewHighlightEngine(rules, login))
+}
+
+func (hc *HasherinoController) GetHighlightRules() ([]*HighlightRule, error) {
+	rules := []*HighlightRule{}
+	result := hc.permDB.Order("id").Find(&rules)
+	return rules, result.Error
+}
+
+// Creates or updates rule, applying it to the following messages
+func (hc *HasherinoController) SaveHighlightRule(rule *HighlightRule) error {
+	if err := ValidateHighlightRule(rule); err != nil {
+		return err
+	}
+	result := hc.permDB.Save(rule)
+	if result.Error != nil {
+		return result.Error
+	}
+	hc.loadHighlights()
+	return nil
+}
+
+func (hc *HasherinoController) DeleteHighlightRule(id uint) error {
+	result := hc.permDB.Delete(&HighlightRule{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	hc.loadHighlights()
+	return nil
+}
+
+// Returns how msg is highlighted, nil for no highlight
+func (hc *HasherinoController) Highlight(msg *ChatMessage) *Highlight {
+	engine := hc.highlights.Load()
+	if engine == nil {
+		return nil
+	}
+	return engine.Match(msg)
+}
+
+// Called with every live message a highlight rule matched, from any channel
+func (hc *HasherinoController) SetMentionCallback(callback func(ChatMessage)) {
+	hc.mentionCallback = callback
+}
+
+func (hc *HasherinoController) getTabAndAccount(channel string) (*Tab, *Account, error) {
+	tab := &Tab{}
+	result := hc.permDB.Take(&tab, "Login = ?", channel)
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	activeAccount, err := hc.GetActiveAccount()
+	if err != nil {
+		return nil, nil, errors.New("no active account")
+	}
+	return tab, activeAccount, nil
+}
+
+func (hc *HasherinoController) GetEmoji(search string) ([]*Emoji, error) {
+	emoji := []*Emoji{}
+	search = "%" + search + "%"
+	result := hc.memDB.Where("slug LIKE ? OR name LIKE ?", search, search).Order("position").Find(&emoji)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return emoji, nil
+}
+
+func (hc *HasherinoController) RemoveTab(id string) error {
+	err := hc.permDB.Transaction(func(tx *gorm.DB) error {
+		tab := &Tab{}
+		result := tx.Take(&tab, "Id = ?", id)
+		if result.Error != nil {
+			return errors.New("tab not found for id " + id)
+		}
+
+		result = tx.Delete(&tab)
+		if result.Error != nil {
+			return result.Error
+		}
+		result = tx.Delete(&PopOut{}, "channel = ?", tab.Login)
+		if result.Error != nil {
+			return result.Error
+		}
+		// The active account may not be the one the channel was subscribed with
+		if eventSub := hc.eventSub.Load(); eventSub != nil {
+			eventSub.UnsubscribeCondition("broadcaster_user_id", tab.Id)
+		}
+
+		result = hc.memDB.Delete(&TempTab{}, "Id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		// Deleted one by one so the orphaned users get cleaned up
+		chatters := []*ChatUserTempTab{}
+		result = hc.memDB.Find(&chatters, "temp_tab_id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		for _, chatter := range chatters {
+			result = hc.memDB.Delete(chatter)
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+
+		err := hc.readWS.Part(tab.Login)
+		if err != nil {
+			return errors.New("failed to part channel" + tab.Login)
+		}
+
+		return nil
+	})
+	hc.loadFilter()
+	hc.configureLogger()
+	return err
+}
+
+func (hc *HasherinoController) GetTabs() ([]*Tab, error) {
+	tabs := []*Tab{}
+	result := hc.permDB.Find(&tabs)
+	return tabs, result.Error
+}
+
+// Tabs of the main window in order
+func (hc *HasherinoController) GetPages() ([]*Page, error) {
+	pages := []*Page{}
+	result := hc.permDB.Order("position").Find(&pages)
+	return pages, result.Error
+}
+
+// Creates page, or updates it if it was already saved. New pages go after the others.
+func (hc *HasherinoController) SavePage(page *Page) error {
+	if err := page.Root.Validate(); err != nil {
+		return err
+	}
+	if page.Id == 0 {
+		var last Page
+		result := hc.permDB.Order("position DESC").Limit(1).Find(&last)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			page.Position = last.Position + 1
+		}
+	}
+	return hc.permDB.Save(page).Error
+}
+
+func (hc *HasherinoController) DeletePage(id uint) error {
+	return hc.permDB.Delete(&Page{}, id).Error
+}
+
+// Window of channel as it was last popped out, a new one if it never was
+func (hc *HasherinoController) GetPopOut(channel string) (*PopOut, error) {
+	popOut := &PopOut{Channel: channel}
+	result := hc.permDB.Limit(1).Find(popOut, "channel = ?", channel)
+	return popOut, result.Error
+}
+
+// Windows that were open when the app was closed
+func (hc *HasherinoController) GetOpenPopOuts() ([]*PopOut, error) {
+	popOuts := []*PopOut{}
+	result := hc.permDB.Find(&popOuts, "open = ?", true)
+	return popOuts, result.Error
+}
+
+func (hc *HasherinoController) SavePopOut(popOut *PopOut) error {
+	return hc.permDB.Save(popOut).Error
+}
+
+func (hc *HasherinoController) SetSelectedPage(id uint) error {
+	return hc.permDB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Page{}).Where("selected = ?", true).Update("selected", false)
+		if result.Error != nil {
+			return result.Error
+		}
+		return tx.Model(&Page{}).Where("id = ?", id).Update("selected", true).Error
+	})
+}
+
+func (hc *HasherinoController) Listen() error {
+	activeAccount, err := hc.GetActiveAccount()
+	if err == nil {
+		if hc.writeWS == nil || hc.writeWS.State == Disconnected {
+			hc.writeWS, err = hc.writeWS.New("oauth:"+activeAccount.Token, activeAccount.Login)
+			if err != nil {
+				return err
+			}
+			err = hc.writeWS.Connect()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if hc.readWS == nil || hc.readWS.State == Disconnected {
+		hc.readWS, err = hc.readWS.New("SCHMOOPIIE", "justinfan71097")
+		if err != nil {
+			return err
+		}
+		err = hc.readWS.Connect()
+		if err != nil {
+			return err
+		}
+	}
+
+	if activeAccount != nil {
+		hc.startEventSub(activeAccount)
+		go func() {
+			if err := hc.SyncBlockedUsers(); err != nil {
+				log.Println("failed to sync blocked users:", err)
+			}
+		}()
+	}
+	// Mentions depend on the active account
+	hc.loadHighlights()
+
+	callbackWrapper := func(message string) {
+		msg, err := ParseMessage(message)
+		if err != nil {
+			log.Printf("Failed to parse message: %s", err)
+			return
+		}
+		if msg.Command == "PRIVMSG" {
+			sentAt := msg.Time()
+			if sentAt.IsZero() {
+				sentAt = time.Now()
+			}
+			hc.lastReceivedMutex.Lock()
+			hc.lastReceived[msg.Channel] = sentAt
+			hc.lastReceivedMutex.Unlock()
+		}
+		if err := hc.chatLogger.LogRaw(msg.Channel, message); err != nil {
+			log.Println("failed to log raw message:", err)
+		}
+		hc.handleMessage(msg)
+	}
+	hc.readWS.OnReconnect = hc.reloadMissed
+
+	for _, channel := range hc.chatChannels() {
+		if !hc.writeWS.HasChannel(channel) {
+			hc.readWS.Join(channel)
+		}
+	}
+
+	go hc.readWS.Listen(callbackWrapper)
+	return nil
+}
+
+// Logs msg as received, then filters it, queues it for indexing and passes it to its channel's callback
+func (hc *HasherinoController) handleMessage(msg *ChatMessage) {
+	// The history reloaded after a reconnect and the live chat may both have a message
+	if id := msg.Id(); msg.Command == "PRIVMSG" && id != "" && !hc.firstHandled(id) {
+		return
+	}
+	hc.recordChatter(msg)
+	// Like the raw log, the log keeps what was sent, whatever the filters hide or replace
+	if err := hc.chatLogger.LogMessage(msg); err != nil {
+		log.Println("failed to log message:", err)
+	}
+	if !hc.ProcessMessage(msg) {
+		return
+	}
+	// Only logged channels are searchable, so the index is pruned along with the logs
+	if hc.chatLogger.Logs(msg.Channel) {
+		indexed := *msg
+		hc.indexer.Add(&indexed)
+	}
+	if msg.Highlight != nil {
+		if msg.Highlight.Sound {
+			PlayHighlightSound()
+		}
+		if hc.mentionCallback != nil {
+			hc.mentionCallback(*msg)
+		}
+	}
+
+	callback, ok := hc.chatCallback(msg.Channel)
+	if !ok {
+		log.Printf("No callback for channel %s.", msg.Channel)
+		return
+	}
+	callback(*msg)
+}
+
+// Shows msg from the history of a reconnect. It wasn't seen live, so unlike handleMessage it
+// plays no sound, notifies no mention and isn't logged out of order.
+func (hc *HasherinoController) handleHistorical(msg *ChatMessage) {
+	if id := msg.Id(); id != "" && !hc.firstHandled(id) {
+		return
+	}
+	if !hc.ProcessMessage(msg) {
+		return
+	}
+	if callback, ok := hc.chatCallback(msg.Channel); ok {
+		callback(*msg)
+	}
+}
+
+// Marks the disconnect in every joined channel, followed by what the recent-messages service has
+// from while it lasted. The history is loaded in the background, so live messages keep coming
+// meanwhile and the ones the history has too are dropped by their id. Local logs have no ids to
+// tell them apart, so they aren't used.
+func (hc *HasherinoController) reloadMissed(downtime time.Duration) {
+	settings, err := hc.GetSettings()
+	if err != nil {
+		log.Println("failed to reload missed messages:", err)
+		return
+	}
+	disconnectedAt := time.Now().Add(-downtime)
+	for _, channel := range hc.readWS.Channels() {
+		hc.lastReceivedMutex.Lock()
+		after, ok := hc.lastReceived[channel]
+		hc.lastReceivedMutex.Unlock()
+		if !ok {
+			after = disconnectedAt
+		}
+
+		// Sent when the last message was, so the missed messages are placed after it
+		marker := NewSystemMessage(channel, "Disconnected for "+downtime.Round(time.Second).String())
+		marker.Tags["tmi-sent-ts"] = strconv.FormatInt(after.UnixMilli(), 10)
+		hc.handleMessage(&marker)
+		if !settings.ChatHistory {
+			continue
+		}
+		go func() {
+			provider := &RecentMessagesProvider{BaseUrl: settings.ChatHistoryUrl}
+			history, err := provider.RecentMessages(channel, settings.ChatMessageLimit)
+			if err != nil {
+				log.Printf("Failed to load history of %s: %s", channel, err)
+				return
+			}
+			for _, msg := range MissedMessages(history, after) {
+				hc.handleHistorical(&msg)
+			}
+		}()
+	}
+}
+
+const handledIdsLimit = 5000
+
+// Remembers id, returning false if it was already handled. Only the latest
+// handledIdsLimit ids are kept, enough to cover a reconnect's history.
+func (hc *HasherinoController) firstHandled(id string) bool {
+	hc.handledIdsMutex.Lock()
+	defer hc.handledIdsMutex.Unlock()
+	if _, ok := hc.handledIds[id]; ok {
+		return false
+	}
+	hc.handledIds[id] = struct{}{}
+	hc.handledIdsOrder = append(hc.handledIdsOrder, id)
+	if len(hc.handledIdsOrder) > handledIdsLimit {
+		delete(hc.handledIds, hc.handledIdsOrder[0])
+		hc.handledIdsOrder = hc.handledIdsOrder[1:]
+	}
+	return true
+}
+
+func (hc *HasherinoController) IsChannelJoined(channel string) bool {
+	if hc.readWS == nil || hc.readWS.State == Disconnected {
+		return false
+	}
+
+	return hc.readWS.HasChannel(channel)
+}
+
+func (hc *HasherinoController) SendMessage(channel string, message string) error {
+	return hc.SendReply(channel, message, "")
+}
+
+// Sends message as a reply to the message with id parentId, a plain message if it's empty
+func (hc *HasherinoController) SendReply(channel string, message string, parentId string) error {
+	tags := map[string]string{"client-nonce": newClientNonce()}
+	if parentId != "" {
+		tags["reply-parent-msg-id"] = parentId
+	}
+	return hc.writeWS.SendTagged(channel, message, tags)
+}
+
+// Random id twitch echoes back in the message, to tell our own messages apart
+func newClientNonce() string {
+	nonce := make([]byte, 16)
+	crand.Read(nonce)
+	return hex.EncodeToString(nonce)
+}
+
+func (hc *HasherinoController) GetSettings() (*AppSettings, error) {
+	appSettings := &AppSettings{}
+	result := hc.permDB.Take(appSettings)
+	return appSettings, result.Error
+}
+
+func (hc *HasherinoController) SetSettings(appSettings *AppSettings) error {
+	result := hc.permDB.Save(appSettings)
+	if result.Error != nil {
+		return result.Error
+	}
+	hc.configureLogger()
+
+	hc.settingsMutex.Lock()
+	callbacks := make([]func(*AppSettings), 0, len(hc.settingsCallbacks))
+	for _, callback := range hc.settingsCallbacks {
+		callbacks = append(callbacks, callback)
+	}
+	hc.settingsMutex.Unlock()
+	for _, callback := range callbacks {
+		settings := *appSettings
+		callback(&settings)
+	}
+	return nil
+}
+
+// Called with the new settings whenever they're saved, so channel's pane can keep them instead of
+// reading them for every message. Replaces the previous callback of channel, nil removes it.
+func (hc *HasherinoController) SetSettingsCallback(channel string, callback func(*AppSettings)) {
+	hc.settingsMutex.Lock()
+	defer hc.settingsMutex.Unlock()
+	if callback == nil {
+		delete(hc.settingsCallbacks, channel)
+		return
+	}
+	hc.settingsCallbacks[channel] = callback
+}
+
+// Saves only the skin tone, so settings changed elsewhere meanwhile are kept
+func (hc *HasherinoController) SetEmojiSkinTone(skinTone SkinTone) error {
+	appSettings, err := hc.GetSettings()
+	if err != nil {
+		return err
+	}
+	return hc.permDB.Model(appSettings).Update("emoji_skin_tone", skinTone).Error
+}
+
+func (hc *HasherinoController) configureLogger() {
+	settings, err := hc.GetSettings()
+	if err != nil {
+		log.Println("failed to load log settings:", err)
+		return
+	}
+	tabs, err := hc.GetTabs()
+	if err != nil {
+		log.Println("failed to load logged channels:", err)
+		return
+	}
+	channels := make(map[string]bool)
+	for _, tab := range tabs {
+		if tab.Logging {
+			channels[tab.Login] = true
+		}
+	}
+	hc.chatLogger.Configure(ChatLogOptions{
+		Channels:      channels,
+		Raw:           settings.ChatLogRaw,
+		MaxSize:       int64(settings.ChatLogMaxSizeMB) * 1024 * 1024,
+		RetentionDays: settings.ChatLogRetentionDays,
+	})
+}
+
+// Enables or disables writing channel's messages to disk
+func (hc *HasherinoController) SetTabLogging(channel string, enabled bool) error {
+	result := hc.permDB.Model(&Tab{}).Where("login = ?", channel).Update("logging", enabled)
+	if result.Error != nil {
+		return result.Error
+	}
+	hc.configureLogger()
+	return nil
+}
+
+// Deletes the logs and searchable messages older than the retention days
+func (hc *HasherinoController) pruneHistory() {
+	if err := hc.chatLogger.Prune(time.Now()); err != nil {
+		log.Println("failed to delete old chat logs:", err)
+	}
+	settings, err := hc.GetSettings()
+	if err != nil || settings.ChatLogRetentionDays <= 0 {
+		return
+	}
+	if err := hc.messageIndex.Prune(time.Now().AddDate(0, 0, -settings.ChatLogRetentionDays)); err != nil {
+		log.Println("failed to delete old searchable messages:", err)
+	}
+}
+
+// Returns the newest messages matching query, see ParseSearchQuery for its syntax
+func (hc *HasherinoController) SearchMessages(query string, limit int) ([]*IndexedMessage, error) {
+	parsed, err := ParseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return hc.messageIndex.Search(parsed, limit)
+}
+
+// Returns the messages sent around message in its channel
+func (hc *HasherinoController) GetMessageContext(message *IndexedMessage, count int) ([]*IndexedMessage, error) {
+	return hc.messageIndex.Context(message, count)
+}
+
+// Messages channel's tab starts with, from the recent-messages service if it's enabled,
+// or the local logs if that's enabled and the service isn't or fails
+func (hc *HasherinoController) GetChatHistory(channel string, limit int) ([]ChatMessage, error) {
+	settings, err := hc.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	providers := []HistoryProvider{}
+	if settings.ChatHistory {
+		providers = append(providers, &RecentMessagesProvider{BaseUrl: settings.ChatHistoryUrl})
+	}
+	if settings.ChatHistoryFromLogs {
+		providers = append(providers, &LocalLogProvider{Folder: hc.chatLogger.folder})
+	}
+	var lastErr error
+	for _, provider := range providers {
+		messages, err := provider.RecentMessages(channel, limit)
+		if err == nil {
+			return messages, nil
+		}
+		log.Printf("Failed to load history of %s: %s", channel, err)
+		lastErr = err
+	}
+	return []ChatMessage{}, lastErr
+}
+
+func GetLogsFolder() (string, error) {
+	dataFolder, err := GetDataFolder()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataFolder, "logs"), nil
+}
+
+func (hc *HasherinoController) GetImageCacheSize() (int64, error) {
+	cache, err := GetImageCache()
+	if err != nil {
+		return 0, err
+	}
+	return cache.Size()
+}
+
+func (hc *HasherinoController) ClearImageCache() error {
+	cache, err := GetImageCache()
+	if err != nil {
+		return err
+	}
+	return cache.Clear()
 }
 
 func GetDataFolder() (string, error) {
@@ -15,3 +15,40 @@ func TestGetEmojiJson(t *testing.T) {
 		t.Error("emojiJson is nill")
 	}
 }
+
+func TestReplaceEmojiShortcodes(t *testing.T) {
+	cases := map[string]string{
+		"hi :grinning_face:":         "hi 😀",
+		":grinning_face::red_heart:": "😀❤️",
+		"time: 10:30:":               "time: 10:30:",
+		":not_an_emoji:":             ":not_an_emoji:",
+	}
+	for text, expected := range cases {
+		if got := hasherino.ReplaceEmojiShortcodes(text); got != expected {
+			t.Errorf("%q: expected %q, got %q", text, expected, got)
+		}
+	}
+}
+
+func TestEmojiSkinToneAndTwemoji(t *testing.T) {
+	hand := &hasherino.Emoji{Emoji: "🖐️", SkinToneSupport: true}
+	if got := hand.WithSkinTone(hasherino.SkinToneDark); got != "🖐🏿" {
+		t.Errorf("expected variation selector to be replaced by the modifier, got %q", got)
+	}
+	if got := hand.GetCacheKey().Id; got != "1f590" {
+		t.Errorf("expected variation selector to be dropped, got %q", got)
+	}
+
+	smile := &hasherino.Emoji{Emoji: "😀"}
+	if got := smile.WithSkinTone(hasherino.SkinToneDark); got != "😀" {
+		t.Errorf("expected emoji without skin tone support to be unchanged, got %q", got)
+	}
+
+	emojiList, err := hasherino.GetEmojiList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if emojiList[0].Emoji != "😀" || emojiList[0].Position != 0 {
+		t.Errorf("expected emoji to keep the file order, first is %q", emojiList[0].Emoji)
+	}
+}
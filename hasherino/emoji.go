@@ -0,0 +1,160 @@
+package hasherino
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+//go:embed data-by-emoji.json
+var emojiJSONData []byte
+
+type SkinTone int
+
+const (
+	SkinToneDefault SkinTone = iota
+	SkinToneLight
+	SkinToneMediumLight
+	SkinToneMedium
+	SkinToneMediumDark
+	SkinToneDark
+)
+
+const (
+	variationSelector16 = '\uFE0F'
+	zeroWidthJoiner     = '\u200D'
+)
+
+var skinToneModifiers = map[SkinTone]rune{
+	SkinToneLight:       '\U0001F3FB',
+	SkinToneMediumLight: '\U0001F3FC',
+	SkinToneMedium:      '\U0001F3FD',
+	SkinToneMediumDark:  '\U0001F3FE',
+	SkinToneDark:        '\U0001F3FF',
+}
+
+var SkinToneNames = []string{"Default", "Light", "Medium-Light", "Medium", "Medium-Dark", "Dark"}
+
+type EmojiJson struct {
+	Name            string `json:"name"`
+	Slug            string `json:"slug"`
+	Group           string `json:"group"`
+	EmojiVersion    string `json:"emoji_version"`
+	UnicodeVersion  string `json:"unicode_version"`
+	SkinToneSupport bool   `json:"skin_tone_support"`
+}
+
+type EmojiJsonMap map[string]EmojiJson
+
+var (
+	cachedEmojiJson  *EmojiJsonMap
+	cachedEmojiList  []*Emoji
+	cachedEmojiSlugs map[string]*Emoji
+	emojiLoadErr     error
+	emojiLoadOnce    sync.Once
+)
+
+// Parses the embedded emoji data once, keeping the file's order for the picker
+func loadEmoji() {
+	emojiJson := EmojiJsonMap{}
+	list := []*Emoji{}
+	slugs := make(map[string]*Emoji)
+
+	decoder := json.NewDecoder(bytes.NewReader(emojiJSONData))
+	if _, err := decoder.Token(); err != nil {
+		emojiLoadErr = err
+		return
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			emojiLoadErr = err
+			return
+		}
+		key, ok := token.(string)
+		if !ok {
+			emojiLoadErr = errors.New("invalid emoji data")
+			return
+		}
+		var data EmojiJson
+		if err := decoder.Decode(&data); err != nil {
+			emojiLoadErr = err
+			return
+		}
+		emojiJson[key] = data
+		emoji := &Emoji{
+			Emoji:           key,
+			Name:            data.Name,
+			Slug:            data.Slug,
+			Group:           data.Group,
+			SkinToneSupport: data.SkinToneSupport,
+			Position:        len(list),
+		}
+		list = append(list, emoji)
+		slugs[data.Slug] = emoji
+	}
+
+	cachedEmojiJson = &emojiJson
+	cachedEmojiList = list
+	cachedEmojiSlugs = slugs
+}
+
+func GetEmojiJSONMap() (*EmojiJsonMap, error) {
+	emojiLoadOnce.Do(loadEmoji)
+	return cachedEmojiJson, emojiLoadErr
+}
+
+// Returns every emoji in unicode order. The emoji are shared, don't modify them.
+func GetEmojiList() ([]*Emoji, error) {
+	emojiLoadOnce.Do(loadEmoji)
+	return cachedEmojiList, emojiLoadErr
+}
+
+var shortcodeRegex = regexp.MustCompile(`:([a-z0-9_]+):`)
+
+// Replaces every known :shortcode: in text with its emoji
+func ReplaceEmojiShortcodes(text string) string {
+	if !strings.Contains(text, ":") {
+		return text
+	}
+	emojiLoadOnce.Do(loadEmoji)
+	if emojiLoadErr != nil {
+		return text
+	}
+	return shortcodeRegex.ReplaceAllStringFunc(text, func(shortcode string) string {
+		emoji, ok := cachedEmojiSlugs[shortcode[1:len(shortcode)-1]]
+		if !ok {
+			return shortcode
+		}
+		return emoji.Emoji
+	})
+}
+
+// Returns up to limit emoji whose shortcode contains search, the ones starting with it first
+func SearchEmojiShortcodes(search string, limit int) []*Emoji {
+	emojiList, err := GetEmojiList()
+	if err != nil || search == "" {
+		return nil
+	}
+	search = strings.ToLower(search)
+	prefixed, contained := []*Emoji{}, []*Emoji{}
+	for _, emoji := range emojiList {
+		if strings.HasPrefix(emoji.Slug, search) {
+			prefixed = append(prefixed, emoji)
+		} else if strings.Contains(emoji.Slug, search) {
+			contained = append(contained, emoji)
+		}
+		if len(prefixed) >= limit {
+			break
+		}
+	}
+	result := append(prefixed, contained...)
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
@@ -0,0 +1,420 @@
+package hasherino
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+const (
+	eventSubUrl = "wss://eventsub.wss.twitch.tv/ws"
+	// Extra time given to keepalive messages before the connection is considered dead
+	eventSubKeepaliveGrace = 5 * time.Second
+	// Message ids remembered to drop duplicates, twitch may resend them around reconnects
+	eventSubSeenMessages = 100
+)
+
+type EventSubMessage struct {
+	Metadata struct {
+		MessageId        string `json:"message_id"`
+		MessageType      string `json:"message_type"`
+		SubscriptionType string `json:"subscription_type"`
+	} `json:"metadata"`
+	Payload struct {
+		Session struct {
+			Id                      string `json:"id"`
+			KeepaliveTimeoutSeconds int    `json:"keepalive_timeout_seconds"`
+			ReconnectUrl            string `json:"reconnect_url"`
+		} `json:"session"`
+		Subscription struct {
+			Type      string            `json:"type"`
+			Version   string            `json:"version"`
+			Status    string            `json:"status"`
+			Condition map[string]string `json:"condition"`
+		} `json:"subscription"`
+		Event json.RawMessage `json:"event"`
+	} `json:"payload"`
+}
+
+// An event to receive, see https://dev.twitch.tv/docs/eventsub/eventsub-subscription-types/
+type EventSubSubscription struct {
+	Type      string
+	Version   string
+	Condition map[string]string
+}
+
+type WhisperEvent struct {
+	FromUserId    string `json:"from_user_id"`
+	FromUserLogin string `json:"from_user_login"`
+	FromUserName  string `json:"from_user_name"`
+	ToUserId      string `json:"to_user_id"`
+	WhisperId     string `json:"whisper_id"`
+	Whisper       struct {
+		Text string `json:"text"`
+	} `json:"whisper"`
+}
+
+type RedemptionEvent struct {
+	BroadcasterUserId    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	UserId               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserName             string `json:"user_name"`
+	UserInput            string `json:"user_input"`
+	Reward               struct {
+		Id    string `json:"id"`
+		Title string `json:"title"`
+		Cost  int    `json:"cost"`
+	} `json:"reward"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+type PollChoice struct {
+	Id    string `json:"id"`
+	Title string `json:"title"`
+	Votes int    `json:"votes"`
+}
+
+// Sent when a poll begins, gets votes and ends. Status is only set on the end event.
+type PollEvent struct {
+	Id                   string       `json:"id"`
+	BroadcasterUserId    string       `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string       `json:"broadcaster_user_login"`
+	Title                string       `json:"title"`
+	Choices              []PollChoice `json:"choices"`
+	Status               string       `json:"status"` // completed, terminated or archived
+	EndsAt               time.Time    `json:"ends_at"`
+}
+
+func (p *PollEvent) Ended() bool {
+	return p.Status != ""
+}
+
+type PredictionOutcome struct {
+	Id            string `json:"id"`
+	Title         string `json:"title"`
+	Color         string `json:"color"`
+	Users         int    `json:"users"`
+	ChannelPoints int    `json:"channel_points"`
+}
+
+// Sent when a prediction begins, gets points, locks and ends. Status is only set on the end event.
+type PredictionEvent struct {
+	Id                   string              `json:"id"`
+	BroadcasterUserId    string              `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string              `json:"broadcaster_user_login"`
+	Title                string              `json:"title"`
+	Outcomes             []PredictionOutcome `json:"outcomes"`
+	WinningOutcomeId     string              `json:"winning_outcome_id"`
+	Status               string              `json:"status"` // resolved or canceled
+	LocksAt              time.Time           `json:"locks_at"`
+	LockedAt             time.Time           `json:"locked_at"`
+}
+
+func (p *PredictionEvent) Locked() bool {
+	return !p.LockedAt.IsZero()
+}
+
+func (p *PredictionEvent) Ended() bool {
+	return p.Status != ""
+}
+
+// Sent when AutoMod holds a message and when it's resolved. Status is only set on the update event.
+type AutoModMessageEvent struct {
+	BroadcasterUserId    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	UserId               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserName             string `json:"user_name"`
+	MessageId            string `json:"message_id"`
+	Message              struct {
+		Text string `json:"text"`
+	} `json:"message"`
+	Category          string    `json:"category"`
+	Level             int       `json:"level"`
+	HeldAt            time.Time `json:"held_at"`
+	Status            string    `json:"status"` // Approved, Denied or Expired
+	ModeratorUserName string    `json:"moderator_user_name"`
+}
+
+func (a *AutoModMessageEvent) Resolved() bool {
+	return a.Status != ""
+}
+
+// Event of a joined channel, only one of the fields is set
+type ChannelEvent struct {
+	Redemption *RedemptionEvent
+	Poll       *PollEvent
+	Prediction *PredictionEvent
+	AutoMod    *AutoModMessageEvent
+}
+
+// A connected websocket and the session twitch created for it
+type eventSubSession struct {
+	id         string
+	keepalive  time.Duration
+	connection *websocket.Conn
+}
+
+// EventSub websocket client. It keeps a set of subscriptions, created through the subscribe
+// function every time a new session starts, and dispatches notifications to the handler
+// registered for their type. Reconnect requests move to the new url keeping the subscriptions,
+// while lost connections start a new session.
+type EventSubClient struct {
+	// Called when twitch revokes a subscription, e.g. when the token loses a scope. The
+	// subscription is already out of the set by then.
+	OnRevocation func(subscription EventSubSubscription, status string)
+
+	url       string
+	subscribe func(sessionId string, subscription EventSubSubscription) error
+
+	mutex         sync.Mutex
+	sessionId     string
+	subscriptions map[string]EventSubSubscription
+	handlers      map[string]func(json.RawMessage)
+	seen          []string
+}
+
+func NewEventSubClient(url string, subscribe func(sessionId string, subscription EventSubSubscription) error) *EventSubClient {
+	return &EventSubClient{
+		url:           url,
+		subscribe:     subscribe,
+		subscriptions: make(map[string]EventSubSubscription),
+		handlers:      make(map[string]func(json.RawMessage)),
+	}
+}
+
+// Registers handler for notifications of eventType, decoding their event into T
+func HandleEventSub[T any](c *EventSubClient, eventType string, handler func(*T)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.handlers[eventType] = func(raw json.RawMessage) {
+		event := new(T)
+		if err := json.Unmarshal(raw, event); err != nil {
+			log.Printf("Failed to parse eventsub %s event: %s", eventType, err)
+			return
+		}
+		handler(event)
+	}
+}
+
+func subscriptionKey(subscription EventSubSubscription) string {
+	condition, _ := json.Marshal(subscription.Condition)
+	return subscription.Type + "/" + subscription.Version + string(condition)
+}
+
+// Adds subscription to the set, creating it right away if there's a session
+func (c *EventSubClient) Subscribe(subscription EventSubSubscription) error {
+	c.mutex.Lock()
+	key := subscriptionKey(subscription)
+	_, exists := c.subscriptions[key]
+	c.subscriptions[key] = subscription
+	sessionId := c.sessionId
+	c.mutex.Unlock()
+
+	if exists || sessionId == "" {
+		return nil
+	}
+	return c.subscribe(sessionId, subscription)
+}
+
+// Removes subscription from the set, it stays active until the session ends
+func (c *EventSubClient) Unsubscribe(subscription EventSubSubscription) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.subscriptions, subscriptionKey(subscription))
+}
+
+// Removes the subscriptions whose condition has field set to value, whichever account they
+// were made for, e.g. every subscription of a broadcaster_user_id
+func (c *EventSubClient) UnsubscribeCondition(field string, value string) {
+	c.removeWhere(func(subscription EventSubSubscription) bool {
+		return subscription.Condition[field] == value
+	})
+}
+
+// Removes the subscriptions matching revoked from the set, so new sessions don't create them
+// again. Twitch echoes the condition with optional fields filled in, so only the fields of the
+// subscriptions in the set are compared.
+func (c *EventSubClient) dropRevoked(revoked EventSubSubscription) {
+	c.removeWhere(func(subscription EventSubSubscription) bool {
+		if subscription.Type != revoked.Type {
+			return false
+		}
+		for field, value := range subscription.Condition {
+			if revoked.Condition[field] != value {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (c *EventSubClient) removeWhere(match func(EventSubSubscription) bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key, subscription := range c.subscriptions {
+		if match(subscription) {
+			delete(c.subscriptions, key)
+		}
+	}
+}
+
+// Connects and handles messages until ctx is done, reconnecting whenever the connection is lost
+func (c *EventSubClient) Run(ctx context.Context) error {
+	for {
+		session, err := c.connect(ctx, c.url)
+		if err == nil {
+			c.startSession(session.id)
+			err = c.listen(ctx, session)
+		}
+		c.setSessionId("")
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Println("eventsub disconnected:", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// Dials url and waits for the welcome message with the session id
+func (c *EventSubClient) connect(ctx context.Context, url string) (*eventSubSession, error) {
+	connection, _, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Twitch sends the welcome right away, a slow one means something is wrong
+	welcomeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	welcome, err := readEventSubMessage(welcomeCtx, connection)
+	if err != nil {
+		connection.Close(websocket.StatusNormalClosure, "")
+		return nil, err
+	}
+	if welcome.Metadata.MessageType != "session_welcome" {
+		connection.Close(websocket.StatusNormalClosure, "")
+		return nil, errors.New("expected eventsub welcome, got " + welcome.Metadata.MessageType)
+	}
+	return &eventSubSession{
+		id:         welcome.Payload.Session.Id,
+		keepalive:  time.Duration(welcome.Payload.Session.KeepaliveTimeoutSeconds) * time.Second,
+		connection: connection,
+	}, nil
+}
+
+func (c *EventSubClient) setSessionId(sessionId string) {
+	c.mutex.Lock()
+	c.sessionId = sessionId
+	c.mutex.Unlock()
+}
+
+// New sessions start without subscriptions, so every one of them is created again
+func (c *EventSubClient) startSession(sessionId string) {
+	c.mutex.Lock()
+	c.sessionId = sessionId
+	subscriptions := make([]EventSubSubscription, 0, len(c.subscriptions))
+	for _, subscription := range c.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	c.mutex.Unlock()
+
+	for _, subscription := range subscriptions {
+		if err := c.subscribe(sessionId, subscription); err != nil {
+			log.Printf("Failed to subscribe to %s: %s", subscription.Type, err)
+		}
+	}
+}
+
+// Handles the session's messages until its connection fails, following reconnect requests
+func (c *EventSubClient) listen(ctx context.Context, session *eventSubSession) error {
+	defer func() {
+		session.connection.Close(websocket.StatusNormalClosure, "")
+	}()
+	for {
+		readCtx := ctx
+		cancel := context.CancelFunc(func() {})
+		if session.keepalive > 0 {
+			readCtx, cancel = context.WithTimeout(ctx, session.keepalive+eventSubKeepaliveGrace)
+		}
+		message, err := readEventSubMessage(readCtx, session.connection)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if c.isDuplicate(message.Metadata.MessageId) {
+			continue
+		}
+
+		switch message.Metadata.MessageType {
+		case "notification":
+			c.mutex.Lock()
+			handler, ok := c.handlers[message.Metadata.SubscriptionType]
+			c.mutex.Unlock()
+			if ok {
+				handler(message.Payload.Event)
+			} else {
+				log.Println("No eventsub handler for " + message.Metadata.SubscriptionType)
+			}
+		case "session_keepalive":
+		case "session_reconnect":
+			// The old connection keeps working until the new one is welcomed
+			next, err := c.connect(ctx, message.Payload.Session.ReconnectUrl)
+			if err != nil {
+				return err
+			}
+			session.connection.Close(websocket.StatusNormalClosure, "")
+			session = next
+			c.setSessionId(session.id)
+		case "revocation":
+			revoked := message.Payload.Subscription
+			log.Printf("Eventsub subscription %s revoked: %s", revoked.Type, revoked.Status)
+			subscription := EventSubSubscription{Type: revoked.Type, Version: revoked.Version, Condition: revoked.Condition}
+			c.dropRevoked(subscription)
+			if c.OnRevocation != nil {
+				c.OnRevocation(subscription, revoked.Status)
+			}
+		default:
+			log.Println("Unhandled eventsub message: " + message.Metadata.MessageType)
+		}
+	}
+}
+
+func (c *EventSubClient) isDuplicate(messageId string) bool {
+	if messageId == "" {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, seen := range c.seen {
+		if seen == messageId {
+			return true
+		}
+	}
+	c.seen = append(c.seen, messageId)
+	if len(c.seen) > eventSubSeenMessages {
+		c.seen = c.seen[1:]
+	}
+	return false
+}
+
+func readEventSubMessage(ctx context.Context, connection *websocket.Conn) (*EventSubMessage, error) {
+	_, content, err := connection.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	message := &EventSubMessage{}
+	if err := json.Unmarshal(content, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
@@ -0,0 +1,220 @@
+package hasherino_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"nhooyr.io/websocket"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+type testEvent struct {
+	Text string `json:"text"`
+}
+
+// Stand-in eventsub server, each connection gets a welcome then runs the script
+func newEventSubServer(t *testing.T, script func(ctx context.Context, c *websocket.Conn, sessionId string)) *httptest.Server {
+	sessions := 0
+	var mutex sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer c.CloseNow()
+		mutex.Lock()
+		sessions++
+		sessionId := fmt.Sprintf("%s-%d", r.URL.Path, sessions)
+		mutex.Unlock()
+
+		welcome := `{"metadata":{"message_id":"w-` + sessionId + `","message_type":"session_welcome"},` +
+			`"payload":{"session":{"id":"` + sessionId + `","keepalive_timeout_seconds":10}}}`
+		if err := c.Write(r.Context(), websocket.MessageText, []byte(welcome)); err != nil {
+			return
+		}
+		script(r.Context(), c, sessionId)
+		// Keep the connection open until the client closes it
+		c.Read(r.Context())
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func notification(id string, text string) []byte {
+	return []byte(`{"metadata":{"message_id":"` + id + `","message_type":"notification","subscription_type":"test.event"},` +
+		`"payload":{"event":{"text":"` + text + `"}}}`)
+}
+
+func TestEventSubReconnect(t *testing.T) {
+	var server *httptest.Server
+	server = newEventSubServer(t, func(ctx context.Context, c *websocket.Conn, sessionId string) {
+		if strings.HasPrefix(sessionId, "/reconnect") {
+			c.Write(ctx, websocket.MessageText, notification("2", "after"))
+			return
+		}
+		c.Write(ctx, websocket.MessageText, notification("1", "before"))
+		reconnectUrl := "ws" + strings.TrimPrefix(server.URL, "http") + "/reconnect"
+		c.Write(ctx, websocket.MessageText, []byte(`{"metadata":{"message_id":"r","message_type":"session_reconnect"},`+
+			`"payload":{"session":{"reconnect_url":"`+reconnectUrl+`"}}}`))
+		// Duplicated on the old connection, should be dropped
+		c.Write(ctx, websocket.MessageText, notification("1", "before"))
+	})
+
+	var mutex sync.Mutex
+	subscribed := []string{}
+	received := make(chan string, 10)
+	client := hasherino.NewEventSubClient("ws"+strings.TrimPrefix(server.URL, "http"),
+		func(sessionId string, subscription hasherino.EventSubSubscription) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			subscribed = append(subscribed, sessionId+" "+subscription.Type)
+			return nil
+		})
+	hasherino.HandleEventSub(client, "test.event", func(event *testEvent) {
+		received <- event.Text
+	})
+	client.Subscribe(hasherino.EventSubSubscription{Type: "test.event", Version: "1"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.Run(ctx)
+
+	for _, expected := range []string{"before", "after"} {
+		select {
+		case text := <-received:
+			if text != expected {
+				t.Fatalf("expected %s, got %s", expected, text)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for " + expected)
+		}
+	}
+	select {
+	case text := <-received:
+		t.Fatalf("unexpected event %s", text)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	// Reconnects keep the subscriptions, only the first session creates them
+	if len(subscribed) != 1 || subscribed[0] != "/-1 test.event" {
+		t.Errorf("unexpected subscriptions %v", subscribed)
+	}
+}
+
+func TestEventSubRevocation(t *testing.T) {
+	server := newEventSubServer(t, func(ctx context.Context, c *websocket.Conn, sessionId string) {
+		c.Write(ctx, websocket.MessageText, []byte(`{"metadata":{"message_id":"1","message_type":"revocation",`+
+			`"subscription_type":"test.event"},"payload":{"subscription":{"type":"test.event","version":"1",`+
+			`"status":"authorization_revoked","condition":{"user_id":"1","extra":""}}}}`))
+	})
+
+	created := make(chan string, 10)
+	revoked := make(chan string, 1)
+	client := hasherino.NewEventSubClient("ws"+strings.TrimPrefix(server.URL, "http"),
+		func(sessionId string, subscription hasherino.EventSubSubscription) error {
+			created <- subscription.Type
+			return nil
+		})
+	client.OnRevocation = func(subscription hasherino.EventSubSubscription, status string) {
+		revoked <- subscription.Type + " " + status
+	}
+	subscription := hasherino.EventSubSubscription{Type: "test.event", Version: "1", Condition: map[string]string{"user_id": "1"}}
+	client.Subscribe(subscription)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.Run(ctx)
+
+	select {
+	case got := <-revoked:
+		if got != "test.event authorization_revoked" {
+			t.Errorf("unexpected revocation %s", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for revocation")
+	}
+	<-created
+
+	// Out of the set, so subscribing again creates it instead of being a no-op
+	client.Subscribe(subscription)
+	select {
+	case <-created:
+	case <-time.After(time.Second):
+		t.Error("expected the revoked subscription to be dropped")
+	}
+}
+
+// Revocations arrive on the eventsub goroutine while the UI opens and closes tabs and account
+// switches restart eventsub, run with -race
+func TestEventSubRevocationWhileClosingChannels(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "gorm.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&hasherino.Tab{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&hasherino.Tab{Id: "1", Login: "forsen"})
+
+	server := newEventSubServer(t, func(ctx context.Context, c *websocket.Conn, sessionId string) {
+		c.Write(ctx, websocket.MessageText, []byte(`{"metadata":{"message_id":"r-`+sessionId+`",`+
+			`"message_type":"revocation","subscription_type":"channel.poll.begin"},"payload":{"subscription":`+
+			`{"type":"channel.poll.begin","version":"1","status":"authorization_revoked",`+
+			`"condition":{"broadcaster_user_id":"1"}}}}`))
+	})
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	create := func(sessionId string, subscription hasherino.EventSubSubscription) error { return nil }
+
+	hc := hasherino.NewTestController(db)
+	t.Cleanup(hc.StopEventSub)
+	revoked := make(chan string, 100)
+	hc.SetChatCallback("forsen", func(message hasherino.ChatMessage) {
+		revoked <- message.Text
+	})
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			hc.SetChatCallback("xqc", func(message hasherino.ChatMessage) {})
+			hc.SetChatCallback("xqc", nil)
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hc.RunEventSub(url, "2", create)
+		}()
+	}
+
+	select {
+	case text := <-revoked:
+		if !strings.HasPrefix(text, "Polls won't show anymore") {
+			t.Errorf("unexpected revocation message %q", text)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("timed out waiting for the revocation")
+	}
+	close(done)
+	wg.Wait()
+}
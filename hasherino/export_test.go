@@ -0,0 +1,30 @@
+package hasherino
+
+import "gorm.io/gorm"
+
+// Controller saving to permDB, without chat connections, for tests of what eventsub drives
+func NewTestController(permDB *gorm.DB) *HasherinoController {
+	return &HasherinoController{
+		permDB:                permDB,
+		callbackMap:           make(map[string]func(ChatMessage)),
+		channelEventCallbacks: make(map[string]func(ChannelEvent)),
+		revoked:               make(map[string][]string),
+		settingsCallbacks:     make(map[string]func(*AppSettings)),
+	}
+}
+
+func (hc *HasherinoController) RunEventSub(
+	url string,
+	accountId string,
+	create func(sessionId string, subscription EventSubSubscription) error,
+) {
+	hc.runEventSub(url, accountId, create)
+}
+
+func (hc *HasherinoController) StopEventSub() {
+	hc.eventSubMutex.Lock()
+	defer hc.eventSubMutex.Unlock()
+	if hc.eventSubCancel != nil {
+		hc.eventSubCancel()
+	}
+}
@@ -0,0 +1,97 @@
+package hasherino
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+var linkRegex = regexp.MustCompile(`(?i)\b(https?://|www\.)\S+|\b[\w-]+\.(com|net|org|tv|gg|io|me|be|ly|co)(/\S*)?\b`)
+
+type phraseMatcher struct {
+	phrase *IgnoredPhrase
+	regex  *regexp.Regexp
+}
+
+// Hides or rewrites messages according to the ignore lists and the filters of their tab
+type MessageFilter struct {
+	users   map[string]bool
+	phrases []phraseMatcher
+	tabs    map[string]*Tab // by login
+}
+
+func ignoredPhraseRegex(phrase *IgnoredPhrase) (*regexp.Regexp, error) {
+	pattern := phrase.Pattern
+	if !phrase.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !phrase.CaseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+func NewMessageFilter(users []*IgnoredUser, phrases []*IgnoredPhrase, tabs []*Tab) *MessageFilter {
+	f := &MessageFilter{users: make(map[string]bool), tabs: make(map[string]*Tab)}
+	for _, user := range users {
+		f.users[strings.ToLower(user.Login)] = true
+	}
+	for _, phrase := range phrases {
+		regex, err := ignoredPhraseRegex(phrase)
+		if err != nil {
+			log.Printf("Skipping ignored phrase %s: %s", phrase.Pattern, err)
+			continue
+		}
+		f.phrases = append(f.phrases, phraseMatcher{phrase: phrase, regex: regex})
+	}
+	for _, tab := range tabs {
+		f.tabs[tab.Login] = tab
+	}
+	return f
+}
+
+// Whether msg passes the filters of tab
+func passesTabFilters(msg *ChatMessage, tab *Tab) bool {
+	if tab.HideCommands && strings.HasPrefix(msg.Text, "!") {
+		return false
+	}
+	if tab.OnlyLinks && !linkRegex.MatchString(msg.Text) {
+		return false
+	}
+	if tab.OnlySubscribers {
+		return isSubscriber(msg)
+	}
+	return true
+}
+
+func isSubscriber(msg *ChatMessage) bool {
+	for _, badge := range ParseBadges(msg.Tags["badges"]) {
+		if badge.Name == "subscriber" || badge.Name == "founder" {
+			return true
+		}
+	}
+	return false
+}
+
+// Replaces the ignored phrases in msg's text, returns false if msg should be hidden
+func (f *MessageFilter) Apply(msg *ChatMessage) bool {
+	if msg.Command != "PRIVMSG" {
+		return true
+	}
+	if f.users[msg.Author] {
+		return false
+	}
+	for _, matcher := range f.phrases {
+		if !matcher.regex.MatchString(msg.Text) {
+			continue
+		}
+		if !matcher.phrase.Replace {
+			return false
+		}
+		msg.Text = matcher.regex.ReplaceAllLiteralString(msg.Text, matcher.phrase.Replacement)
+	}
+	if tab, ok := f.tabs[msg.Channel]; ok {
+		return passesTabFilters(msg, tab)
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package hasherino_test
+
+import (
+	"testing"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+func TestMessageFilter(t *testing.T) {
+	filter := hasherino.NewMessageFilter(
+		[]*hasherino.IgnoredUser{{Login: "SpamBot"}},
+		[]*hasherino.IgnoredPhrase{
+			{Pattern: "buy followers"},
+			{Pattern: `\bf+r+e+\b`, Regex: true, Replace: true, Replacement: "***"},
+		},
+		[]*hasherino.Tab{
+			{Login: "subs", OnlySubscribers: true},
+			{Login: "links", OnlyLinks: true, HideCommands: true},
+		},
+	)
+
+	cases := []struct {
+		channel string
+		author  string
+		text    string
+		badges  string
+		shown   bool
+		result  string
+	}{
+		{"other", "spambot", "hi", "", false, ""},
+		{"other", "foo", "Buy Followers now", "", false, ""},
+		{"other", "foo", "it's FREE", "", true, "it's ***"},
+		{"subs", "foo", "hi", "vip/1", false, ""},
+		{"subs", "foo", "hi", "founder/0", true, "hi"},
+		{"links", "foo", "look at https://example.com", "", true, "look at https://example.com"},
+		{"links", "foo", "look at twitch.tv/foo", "", true, "look at twitch.tv/foo"},
+		{"links", "foo", "no link here", "", false, ""},
+		{"links", "foo", "!watch https://example.com", "", false, ""},
+	}
+	for _, c := range cases {
+		msg := &hasherino.ChatMessage{
+			Channel: c.channel,
+			Command: "PRIVMSG",
+			Author:  c.author,
+			Text:    c.text,
+			Tags:    map[string]string{"badges": c.badges},
+		}
+		shown := filter.Apply(msg)
+		if shown != c.shown {
+			t.Errorf("%s %s %q: expected shown %v", c.channel, c.author, c.text, c.shown)
+		} else if shown && msg.Text != c.result {
+			t.Errorf("%q: expected %q, got %q", c.text, c.result, msg.Text)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package hasherino
+
+import (
+	"regexp"
+	"strings"
+)
+
+type FindMode int
+
+const (
+	FindText FindMode = iota
+	FindRegex
+	FindAuthor
+	FindBadge
+)
+
+var FindModes = []FindMode{FindText, FindRegex, FindAuthor, FindBadge}
+
+func (m FindMode) String() string {
+	return [...]string{"Text", "Regex", "Author", "Badge"}[m]
+}
+
+// Matches the messages of a tab against what was typed in its find bar
+type MessageFinder struct {
+	mode  FindMode
+	query string
+	regex *regexp.Regexp // set for text and regex searches
+}
+
+// Text searches ignore case, regex ones only if the pattern asks to
+func NewMessageFinder(mode FindMode, query string) (*MessageFinder, error) {
+	f := &MessageFinder{mode: mode, query: strings.ToLower(strings.TrimPrefix(query, "@"))}
+	var err error
+	switch mode {
+	case FindText:
+		f.regex, err = regexp.Compile("(?i)" + regexp.QuoteMeta(query))
+	case FindRegex:
+		f.regex, err = regexp.Compile(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// The parts of the text to emphasize, nil if the search isn't about the text
+func (f *MessageFinder) TextRegex() *regexp.Regexp {
+	return f.regex
+}
+
+func (f *MessageFinder) Matches(msg *ChatMessage) bool {
+	switch f.mode {
+	case FindAuthor:
+		return strings.Contains(msg.Author, f.query) || strings.Contains(strings.ToLower(msg.Tags["display-name"]), f.query)
+	case FindBadge:
+		for _, badge := range ParseBadges(msg.Tags["badges"]) {
+			if strings.Contains(badge.Name, f.query) {
+				return true
+			}
+		}
+		return false
+	}
+	return f.regex.MatchString(msg.ReplyText())
+}
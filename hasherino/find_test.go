@@ -0,0 +1,42 @@
+package hasherino_test
+
+import (
+	"testing"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+func TestMessageFinder(t *testing.T) {
+	msg := &hasherino.ChatMessage{
+		Command: "PRIVMSG",
+		Author:  "foo",
+		Text:    "Hello there",
+		Tags:    map[string]string{"display-name": "FooBar", "badges": "moderator/1,subscriber/12"},
+	}
+	cases := []struct {
+		mode     hasherino.FindMode
+		query    string
+		expected bool
+	}{
+		{hasherino.FindText, "hello", true},
+		{hasherino.FindText, "l.o", false},
+		{hasherino.FindRegex, "^Hel+o", true},
+		{hasherino.FindRegex, "^hello", false},
+		{hasherino.FindAuthor, "@foob", true},
+		{hasherino.FindAuthor, "baz", false},
+		{hasherino.FindBadge, "mod", true},
+		{hasherino.FindBadge, "vip", false},
+	}
+	for _, c := range cases {
+		finder, err := hasherino.NewMessageFinder(c.mode, c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if finder.Matches(msg) != c.expected {
+			t.Errorf("%s %q: expected %v", c.mode, c.query, c.expected)
+		}
+	}
+	if _, err := hasherino.NewMessageFinder(hasherino.FindRegex, "("); err == nil {
+		t.Error("expected an invalid regex to fail")
+	}
+}
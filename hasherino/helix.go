@@ -0,0 +1,253 @@
+package hasherino
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const helixUrl = "https://api.twitch.tv/helix"
+
+// Sends a helix request, encoding body as json when it's not nil and decoding the response into
+// out when it's not nil. Responses other than 2xx are returned as errors with twitch's message.
+func (h *Helix) request(method string, token string, path string, params url.Values, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequest(method, helixUrl+path+"?"+params.Encode(), reader)
+	if err != nil {
+		log.Printf("Failed to create request for helix %s: %s", path, err)
+		return err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+	req.Header.Add("Client-Id", h.appId)
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("Failed to request helix %s: %s", path, err)
+		return err
+	}
+	defer resp.Body.Close()
+	log.Printf("Helix %s %s status code: %d", method, path, resp.StatusCode)
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("Failed to read response body: %s", err)
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var helixError struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(content, &helixError) == nil && helixError.Message != "" {
+			return errors.New(helixError.Message)
+		}
+		return errors.New("helix " + path + " failed with status " + resp.Status)
+	}
+
+	if out == nil || len(content) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(content, out); err != nil {
+		log.Printf("Failed to unmarshal response body: %s", err)
+		return err
+	}
+	return nil
+}
+
+type HelixChatter struct {
+	UserID    string `json:"user_id"`
+	UserLogin string `json:"user_login"`
+	UserName  string `json:"user_name"`
+}
+
+type helixPagination struct {
+	Cursor string `json:"cursor"`
+}
+
+// Returns everyone connected to the broadcaster's chat. moderatorId has to be the token's user
+// and a moderator of the channel.
+func (h *Helix) GetChatters(token string, broadcasterId string, moderatorId string) ([]HelixChatter, error) {
+	chatters := []HelixChatter{}
+	params := url.Values{
+		"broadcaster_id": {broadcasterId},
+		"moderator_id":   {moderatorId},
+		"first":          {"1000"},
+	}
+	for {
+		var page struct {
+			Data       []HelixChatter  `json:"data"`
+			Pagination helixPagination `json:"pagination"`
+		}
+		if err := h.request("GET", token, "/chat/chatters", params, nil, &page); err != nil {
+			return nil, err
+		}
+		chatters = append(chatters, page.Data...)
+
+		if page.Pagination.Cursor == "" {
+			return chatters, nil
+		}
+		params.Set("after", page.Pagination.Cursor)
+	}
+}
+
+// Returns when userId followed the broadcaster, nil if they don't follow.
+// The token's user has to be the broadcaster or one of its moderators.
+func (h *Helix) GetFollowedAt(token string, broadcasterId string, userId string) (*time.Time, error) {
+	var follows struct {
+		Data []struct {
+			FollowedAt time.Time `json:"followed_at"`
+		} `json:"data"`
+	}
+	params := url.Values{"broadcaster_id": {broadcasterId}, "user_id": {userId}}
+	if err := h.request("GET", token, "/channels/followers", params, nil, &follows); err != nil {
+		return nil, err
+	}
+	if len(follows.Data) == 0 {
+		return nil, nil
+	}
+	return &follows.Data[0].FollowedAt, nil
+}
+
+// Bans userId from the broadcaster's chat, for duration if it's not 0
+func (h *Helix) BanUser(token string, broadcasterId string, moderatorId string, userId string, duration time.Duration, reason string) error {
+	type banData struct {
+		UserId   string `json:"user_id"`
+		Duration int    `json:"duration,omitempty"`
+		Reason   string `json:"reason,omitempty"`
+	}
+	body := struct {
+		Data banData `json:"data"`
+	}{banData{UserId: userId, Duration: int(duration.Seconds()), Reason: reason}}
+	params := url.Values{"broadcaster_id": {broadcasterId}, "moderator_id": {moderatorId}}
+	return h.request("POST", token, "/moderation/bans", params, body, nil)
+}
+
+// Removes a ban or timeout
+func (h *Helix) UnbanUser(token string, broadcasterId string, moderatorId string, userId string) error {
+	params := url.Values{"broadcaster_id": {broadcasterId}, "moderator_id": {moderatorId}, "user_id": {userId}}
+	return h.request("DELETE", token, "/moderation/bans", params, nil, nil)
+}
+
+// Allows or denies a message held by AutoMod, moderatorId has to be the token's user
+func (h *Helix) ManageHeldAutoModMessage(token string, moderatorId string, messageId string, allow bool) error {
+	action := "DENY"
+	if allow {
+		action = "ALLOW"
+	}
+	body := struct {
+		UserId    string `json:"user_id"`
+		MessageId string `json:"msg_id"`
+		Action    string `json:"action"`
+	}{moderatorId, messageId, action}
+	return h.request("POST", token, "/moderation/automod/message", url.Values{}, body, nil)
+}
+
+func (h *Helix) BlockUser(token string, userId string) error {
+	params := url.Values{"target_user_id": {userId}}
+	return h.request("PUT", token, "/users/blocks", params, nil, nil)
+}
+
+func (h *Helix) UnblockUser(token string, userId string) error {
+	params := url.Values{"target_user_id": {userId}}
+	return h.request("DELETE", token, "/users/blocks", params, nil, nil)
+}
+
+type HelixBlockedUser struct {
+	UserID      string `json:"user_id"`
+	UserLogin   string `json:"user_login"`
+	DisplayName string `json:"display_name"`
+}
+
+// Returns the users the broadcaster blocked, which has to be the token's user
+func (h *Helix) GetBlockedUsers(token string, broadcasterId string) ([]HelixBlockedUser, error) {
+	blocked := []HelixBlockedUser{}
+	params := url.Values{"broadcaster_id": {broadcasterId}, "first": {"100"}}
+	for {
+		var page struct {
+			Data       []HelixBlockedUser `json:"data"`
+			Pagination helixPagination    `json:"pagination"`
+		}
+		if err := h.request("GET", token, "/users/blocks", params, nil, &page); err != nil {
+			return nil, err
+		}
+		blocked = append(blocked, page.Data...)
+
+		if page.Pagination.Cursor == "" {
+			return blocked, nil
+		}
+		params.Set("after", page.Pagination.Cursor)
+	}
+}
+
+// Whispers message to userId from fromUserId, which has to be the token's user
+func (h *Helix) SendWhisper(token string, fromUserId string, userId string, message string) error {
+	body := struct {
+		Message string `json:"message"`
+	}{message}
+	params := url.Values{"from_user_id": {fromUserId}, "to_user_id": {userId}}
+	return h.request("POST", token, "/whispers", params, body, nil)
+}
+
+type HelixBadgeSet struct {
+	SetID    string `json:"set_id"`
+	Versions []struct {
+		ID         string `json:"id"`
+		ImageURL1X string `json:"image_url_1x"`
+		ImageURL2X string `json:"image_url_2x"`
+		ImageURL4X string `json:"image_url_4x"`
+		Title      string `json:"title"`
+	} `json:"versions"`
+}
+
+// Returns the broadcaster's custom badges, or the global ones when broadcasterId is empty
+func (h *Helix) GetChatBadges(token string, broadcasterId string) ([]HelixBadgeSet, error) {
+	var badges struct {
+		Data []HelixBadgeSet `json:"data"`
+	}
+	path := "/chat/badges/global"
+	params := url.Values{}
+	if broadcasterId != "" {
+		path = "/chat/badges"
+		params.Set("broadcaster_id", broadcasterId)
+	}
+	if err := h.request("GET", token, path, params, nil, &badges); err != nil {
+		return nil, err
+	}
+	return badges.Data, nil
+}
+
+// Subscribes the websocket session to an eventsub event, condition depends on the event type
+func (h *Helix) CreateEventSubSubscription(
+	token string,
+	eventType string,
+	version string,
+	condition map[string]string,
+	sessionId string,
+) error {
+	type transport struct {
+		Method    string `json:"method"`
+		SessionId string `json:"session_id"`
+	}
+	body := struct {
+		Type      string            `json:"type"`
+		Version   string            `json:"version"`
+		Condition map[string]string `json:"condition"`
+		Transport transport         `json:"transport"`
+	}{eventType, version, condition, transport{"websocket", sessionId}}
+	return h.request("POST", token, "/eventsub/subscriptions", url.Values{}, body, nil)
+}
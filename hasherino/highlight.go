@@ -0,0 +1,144 @@
+package hasherino
+
+import (
+	"errors"
+	"log"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// How a message is marked, from the first rule it matched
+type Highlight struct {
+	Color string
+	Sound bool
+}
+
+type highlightMatcher struct {
+	rule  *HighlightRule
+	regex *regexp.Regexp // set for text rules
+}
+
+// Matches messages against the enabled highlight rules
+type HighlightEngine struct {
+	login    string
+	matchers []highlightMatcher
+}
+
+// Compiles the text rules into regular expressions, the mention one using login
+func highlightRegex(rule *HighlightRule, login string) (*regexp.Regexp, error) {
+	flags := "(?i)"
+	if rule.CaseSensitive {
+		flags = ""
+	}
+	switch rule.Kind {
+	case HighlightMention:
+		if login == "" {
+			return nil, errors.New("no active account to detect mentions of")
+		}
+		return regexp.Compile(flags + `\b` + regexp.QuoteMeta(login) + `\b`)
+	case HighlightPhrase:
+		return regexp.Compile(flags + regexp.QuoteMeta(rule.Pattern))
+	case HighlightRegex:
+		return regexp.Compile(flags + rule.Pattern)
+	}
+	return nil, nil
+}
+
+// Returns why rule can't be saved, if it can't
+func ValidateHighlightRule(rule *HighlightRule) error {
+	switch rule.Kind {
+	case HighlightPhrase, HighlightRegex, HighlightUser, HighlightBadge:
+		if strings.TrimSpace(rule.Pattern) == "" {
+			return errors.New(rule.Kind.String() + " highlights need a pattern")
+		}
+	}
+	if rule.Kind == HighlightRegex {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Builds an engine from the enabled rules, login being the account whose mentions are highlighted
+func NewHighlightEngine(rules []*HighlightRule, login string) *HighlightEngine {
+	e := &HighlightEngine{login: login}
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		regex, err := highlightRegex(rule, login)
+		if err != nil {
+			log.Printf("Skipping %s highlight: %s", rule.Kind, err)
+			continue
+		}
+		e.matchers = append(e.matchers, highlightMatcher{rule: rule, regex: regex})
+	}
+	return e
+}
+
+func (m *highlightMatcher) matches(msg *ChatMessage) bool {
+	switch m.rule.Kind {
+	case HighlightMention, HighlightPhrase, HighlightRegex:
+		return m.regex.MatchString(msg.Text)
+	case HighlightUser:
+		return strings.EqualFold(msg.Author, strings.TrimPrefix(m.rule.Pattern, "@"))
+	case HighlightBadge:
+		for _, badge := range ParseBadges(msg.Tags["badges"]) {
+			if strings.EqualFold(badge.Name, m.rule.Pattern) {
+				return true
+			}
+		}
+	case HighlightFirstMessage:
+		return msg.Tags["first-msg"] == "1"
+	}
+	return false
+}
+
+// Returns how msg is highlighted, nil if no rule matches it. The account's own messages never match.
+func (e *HighlightEngine) Match(msg *ChatMessage) *Highlight {
+	if msg.Command != "PRIVMSG" || strings.EqualFold(msg.Author, e.login) {
+		return nil
+	}
+	for _, matcher := range e.matchers {
+		if matcher.matches(msg) {
+			return &Highlight{Color: matcher.rule.Color, Sound: matcher.rule.Sound}
+		}
+	}
+	return nil
+}
+
+// Shortest time between highlight sounds, so a burst of mentions doesn't start a player each
+const highlightSoundInterval = 2 * time.Second
+
+var lastHighlightSound atomic.Int64 // unix nanoseconds
+
+// Plays the system's notification sound through the platform's player, errors are only logged.
+// Calls within highlightSoundInterval of the last sound are ignored.
+func PlayHighlightSound() {
+	now := time.Now().UnixNano()
+	last := lastHighlightSound.Load()
+	if now-last < int64(highlightSoundInterval) || !lastHighlightSound.CompareAndSwap(last, now) {
+		return
+	}
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("powershell", "-c", "[System.Media.SystemSounds]::Asterisk.Play()")
+	case "darwin":
+		cmd = exec.Command("afplay", "/System/Library/Sounds/Ping.aiff")
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("canberra-gtk-play", "-i", "message-new-instant")
+	default:
+		return
+	}
+	go func() {
+		if err := cmd.Run(); err != nil {
+			log.Println("Failed to play highlight sound:", err)
+		}
+	}()
+}
@@ -0,0 +1,63 @@
+package hasherino_test
+
+import (
+	"testing"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+func TestHighlightEngine(t *testing.T) {
+	rules := []*hasherino.HighlightRule{
+		{Kind: hasherino.HighlightMention, Color: "#000001", Sound: true, Enabled: true},
+		{Kind: hasherino.HighlightPhrase, Pattern: "Giveaway", CaseSensitive: true, Color: "#000002", Enabled: true},
+		{Kind: hasherino.HighlightRegex, Pattern: `^!\w+`, Color: "#000003", Enabled: true},
+		{Kind: hasherino.HighlightUser, Pattern: "@Streamer", Color: "#000004", Enabled: true},
+		{Kind: hasherino.HighlightBadge, Pattern: "vip", Color: "#000005", Enabled: true},
+		{Kind: hasherino.HighlightFirstMessage, Color: "#000006", Enabled: true},
+		{Kind: hasherino.HighlightPhrase, Pattern: "disabled", Color: "#000007", Enabled: false},
+	}
+	engine := hasherino.NewHighlightEngine(rules, "me")
+
+	cases := []struct {
+		author string
+		text   string
+		tags   map[string]string
+		color  string
+	}{
+		{"foo", "hey @ME how are you", nil, "#000001"},
+		{"foo", "meme", nil, ""}, // not a whole word
+		{"me", "talking about me", nil, ""},
+		{"foo", "Giveaway time", nil, "#000002"},
+		{"foo", "giveaway time", nil, ""},
+		{"foo", "!command", nil, "#000003"},
+		{"streamer", "hi", nil, "#000004"},
+		{"foo", "hi", map[string]string{"badges": "vip/1"}, "#000005"},
+		{"foo", "hi", map[string]string{"first-msg": "1"}, "#000006"},
+		{"foo", "disabled", nil, ""},
+	}
+	for _, c := range cases {
+		msg := &hasherino.ChatMessage{Command: "PRIVMSG", Author: c.author, Text: c.text, Tags: c.tags}
+		highlight := engine.Match(msg)
+		got := ""
+		if highlight != nil {
+			got = highlight.Color
+		}
+		if got != c.color {
+			t.Errorf("%s: %q expected color %q, got %q", c.author, c.text, c.color, got)
+		}
+	}
+
+	if err := hasherino.ValidateHighlightRule(&hasherino.HighlightRule{Kind: hasherino.HighlightRegex, Pattern: "("}); err == nil {
+		t.Error("expected invalid regex to fail validation")
+	}
+
+	caseSensitive := hasherino.NewHighlightEngine([]*hasherino.HighlightRule{
+		{Kind: hasherino.HighlightMention, CaseSensitive: true, Color: "#000001", Enabled: true},
+	}, "me")
+	if caseSensitive.Match(&hasherino.ChatMessage{Command: "PRIVMSG", Author: "foo", Text: "hey @ME"}) != nil {
+		t.Error("expected a case sensitive mention rule to skip other cases")
+	}
+	if caseSensitive.Match(&hasherino.ChatMessage{Command: "PRIVMSG", Author: "foo", Text: "hey @me"}) == nil {
+		t.Error("expected a case sensitive mention rule to match the login")
+	}
+}
@@ -0,0 +1,266 @@
+package hasherino
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultRecentMessagesUrl = "https://recent-messages.robotty.de"
+
+// Source of the messages a tab starts with, oldest first
+type HistoryProvider interface {
+	RecentMessages(channel string, limit int) ([]ChatMessage, error)
+}
+
+// Tags msg as history, which the recent-messages service already does
+func markHistorical(msg *ChatMessage) {
+	if msg.Tags == nil {
+		msg.Tags = make(map[string]string)
+	}
+	msg.Tags["historical"] = "1"
+	if msg.Tags["rm-received-ts"] == "" {
+		msg.Tags["rm-received-ts"] = msg.Tags["tmi-sent-ts"]
+	}
+}
+
+// Whether msg came from a HistoryProvider rather than chat
+func (m *ChatMessage) Historical() bool {
+	return m.Tags["historical"] == "1"
+}
+
+// The chat messages of history sent after after, like the ones missed while disconnected
+func MissedMessages(history []ChatMessage, after time.Time) []ChatMessage {
+	missed := []ChatMessage{}
+	for _, msg := range history {
+		if msg.Command == "PRIVMSG" && msg.Time().After(after) {
+			missed = append(missed, msg)
+		}
+	}
+	return missed
+}
+
+type ChatMessagesJson struct {
+	Messages  []string `json:"messages"`
+	Error     any      `json:"error"`
+	ErrorCode any      `json:"error_code"`
+}
+
+// Loads history from a recent-messages service, https://recent-messages.robotty.de or a self-hosted one
+type RecentMessagesProvider struct {
+	BaseUrl string // DefaultRecentMessagesUrl if empty
+}
+
+func (p *RecentMessagesProvider) RecentMessages(channel string, limit int) ([]ChatMessage, error) {
+	baseUrl := p.BaseUrl
+	if baseUrl == "" {
+		baseUrl = DefaultRecentMessagesUrl
+	}
+	requestUrl := strings.TrimRight(baseUrl, "/") + "/api/v2/recent-messages/" + url.PathEscape(channel) +
+		"?limit=" + strconv.Itoa(limit)
+
+	resp, err := http.Get(requestUrl)
+	if err != nil {
+		log.Printf("Failed to get chat history: %s", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	log.Printf("Chat history status code: %d", resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("Failed to read response body: %s", err)
+		return nil, err
+	}
+
+	var messagesJson ChatMessagesJson
+	if err := json.Unmarshal(body, &messagesJson); err != nil {
+		log.Printf("Failed to unmarshal response body: %s", err)
+		return nil, err
+	}
+	if messagesJson.Error != nil {
+		return nil, fmt.Errorf("failed to get chat history: %v", messagesJson.Error)
+	}
+
+	messages := []ChatMessage{}
+	for _, line := range messagesJson.Messages {
+		msg, err := ParseMessage(line)
+		if err != nil {
+			log.Printf("Skipping history line %q: %s", line, err)
+			continue
+		}
+		markHistorical(msg)
+		messages = append(messages, *msg)
+	}
+	return messages, nil
+}
+
+// Replays history from the chat logs, the raw IRC ones when there are any since they lose nothing
+type LocalLogProvider struct {
+	Folder string
+}
+
+type historyLogFile struct {
+	name string
+	date string
+	part int
+	raw  bool // a .irc.log of the raw IRC lines
+}
+
+var (
+	logFileRegex = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})(\.irc)?(?:\.(\d+))?\.log$`)
+	// Logs written before the login was added only have the name
+	logLineRegex = regexp.MustCompile(`^\[(\d{2}:\d{2}:\d{2})\] ([^:]+?)(?: \(([a-z0-9_]+)\))?: (.*)$`)
+)
+
+// The channel's log files, newest first. Days with raw logs only use those, they have the
+// moderation the plain logs lack.
+func (p *LocalLogProvider) logFiles(channel string) ([]historyLogFile, error) {
+	entries, err := os.ReadDir(filepath.Join(p.Folder, channel))
+	if err != nil {
+		return nil, err
+	}
+	all := []historyLogFile{}
+	rawDates := map[string]bool{}
+	for _, entry := range entries {
+		match := logFileRegex.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		part, _ := strconv.Atoi(match[3])
+		file := historyLogFile{name: entry.Name(), date: match[1], part: part, raw: match[2] != ""}
+		if file.raw {
+			rawDates[file.date] = true
+		}
+		all = append(all, file)
+	}
+	files := []historyLogFile{}
+	for _, file := range all {
+		if file.raw || !rawDates[file.date] {
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].date != files[j].date {
+			return files[i].date > files[j].date
+		}
+		return files[i].part > files[j].part
+	})
+	return files, nil
+}
+
+// Turns a "[15:04:05] Display (login): text" line of the log from date back into a message. Lines
+// without the login leave the author empty, a localized display name doesn't give it away.
+func parseLogLine(channel string, date string, line string) (*ChatMessage, error) {
+	match := logLineRegex.FindStringSubmatch(line)
+	if match == nil {
+		return nil, errors.New("not a chat message")
+	}
+	sentAt, err := time.ParseInLocation(logDateFormat+" 15:04:05", date+" "+match[1], time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &ChatMessage{
+		Channel: channel,
+		Command: "PRIVMSG",
+		Author:  match[3],
+		Text:    match[4],
+		Tags: map[string]string{
+			"display-name": match[2],
+			"tmi-sent-ts":  strconv.FormatInt(sentAt.UnixMilli(), 10),
+		},
+	}, nil
+}
+
+func (p *LocalLogProvider) readLogFile(channel string, file historyLogFile) ([]ChatMessage, error) {
+	f, err := os.Open(filepath.Join(p.Folder, channel, file.name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	messages := []ChatMessage{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || (!file.raw && strings.HasPrefix(line, "#")) {
+			continue
+		}
+		var msg *ChatMessage
+		if file.raw {
+			msg, err = ParseMessage(line)
+		} else {
+			msg, err = parseLogLine(channel, file.date, line)
+		}
+		if err != nil {
+			log.Printf("Skipping log line %q: %s", line, err)
+			continue
+		}
+		messages = append(messages, *msg)
+	}
+	return messages, scanner.Err()
+}
+
+func (p *LocalLogProvider) RecentMessages(channel string, limit int) ([]ChatMessage, error) {
+	files, err := p.logFiles(channel)
+	if os.IsNotExist(err) {
+		return []ChatMessage{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Newest files first until there are enough chat messages
+	lines := []ChatMessage{}
+	chatMessages := 0
+	for _, file := range files {
+		if chatMessages >= limit {
+			break
+		}
+		fileLines, err := p.readLogFile(channel, file)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range fileLines {
+			if line.Command == "PRIVMSG" {
+				chatMessages++
+			}
+		}
+		lines = append(fileLines, lines...)
+	}
+
+	// Raw logs have the moderation that happened, applied the way recent-messages does
+	messages := []ChatMessage{}
+	for _, line := range lines {
+		switch line.Command {
+		case "PRIVMSG":
+			markHistorical(&line)
+			messages = append(messages, line)
+		case "CLEARMSG":
+			for i := range messages {
+				if messages[i].Id() == line.Tags["target-msg-id"] {
+					messages[i].Tags["rm-deleted"] = "1"
+				}
+			}
+		case "CLEARCHAT":
+			for i := range messages {
+				if line.Text == "" || messages[i].Author == line.Text {
+					messages[i].Tags["rm-deleted"] = "1"
+				}
+			}
+		}
+	}
+	return messages[max(0, len(messages)-limit):], nil
+}
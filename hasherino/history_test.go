@@ -0,0 +1,105 @@
+package hasherino_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+func TestRecentMessagesProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/recent-messages/foo" || r.URL.Query().Get("limit") != "10" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		fmt.Fprint(w, `{"messages": [
+			"@historical=1;id=1;rm-received-ts=1 :bar!bar@bar PRIVMSG #foo :hello",
+			"",
+			"@id=2 :baz!baz@baz PRIVMSG #foo :there"
+		], "error": null}`)
+	}))
+	defer server.Close()
+
+	provider := &hasherino.RecentMessagesProvider{BaseUrl: server.URL + "/"}
+	messages, err := provider.RecentMessages("foo", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].Text != "hello" || messages[1].Author != "baz" {
+		t.Fatalf("expected the bad line to be skipped, got %v", messages)
+	}
+	for _, message := range messages {
+		if !message.Historical() {
+			t.Errorf("expected %s to be historical", message.Id())
+		}
+	}
+}
+
+func TestLocalLogProvider(t *testing.T) {
+	folder := t.TempDir()
+	channelFolder := filepath.Join(folder, "foo")
+	if err := os.MkdirAll(channelFolder, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name string, content string) {
+		if err := os.WriteFile(filepath.Join(channelFolder, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("2024-05-01.log", "# Start logging at 2024-05-01 12:00:00 UTC\n[12:00:00] Bar: first\nnot a message\n")
+	write("2024-05-02.log", "[08:30:00] Bar: second\n")
+	write("2024-05-02.1.log", "[09:00:00] Baz (baz): third\n[09:10:00] 바즈 (baz): fourth\n")
+
+	provider := &hasherino.LocalLogProvider{Folder: folder}
+	messages, err := provider.RecentMessages("foo", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 3 || messages[0].Text != "second" || messages[1].Text != "third" || messages[2].Text != "fourth" {
+		t.Fatalf("expected the newest 3 messages, got %v", messages)
+	}
+	if messages[1].Author != "baz" || messages[1].AuthorName() != "Baz" || !messages[1].Historical() {
+		t.Errorf("unexpected message %+v", messages[1])
+	}
+	// Localized display names don't give the login away, older logs only have the name
+	if messages[2].Author != "baz" || messages[2].Tags["display-name"] != "바즈" {
+		t.Errorf("unexpected localized message %+v", messages[2])
+	}
+	if messages[0].Author != "" || messages[0].AuthorName() != "Bar" {
+		t.Errorf("expected no author for a line without the login, got %+v", messages[0])
+	}
+
+	// Raw logs are preferred for their day, with their moderation applied, older days keep the plain ones
+	write("2024-05-02.irc.log", "@id=1 :bar!bar@bar PRIVMSG #foo :raw one\n"+
+		"@id=2 :baz!baz@baz PRIVMSG #foo :raw two\n"+
+		"@target-msg-id=1 :tmi.twitch.tv CLEARMSG #foo :raw one\n")
+	messages, err = provider.RecentMessages("foo", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 3 || messages[0].Text != "first" || messages[1].Tags["rm-deleted"] != "1" ||
+		messages[2].Tags["rm-deleted"] == "1" {
+		t.Errorf("expected the plain log then the raw one with its first message deleted, got %v", messages)
+	}
+
+	if messages, err := provider.RecentMessages("missing", 10); err != nil || len(messages) != 0 {
+		t.Errorf("expected no history for a channel without logs, got %v %v", messages, err)
+	}
+}
+
+func TestMissedMessages(t *testing.T) {
+	history := []hasherino.ChatMessage{
+		{Command: "PRIVMSG", Text: "before", Tags: map[string]string{"tmi-sent-ts": "1000"}},
+		{Command: "CLEARCHAT", Tags: map[string]string{"tmi-sent-ts": "3000"}},
+		{Command: "PRIVMSG", Text: "during", Tags: map[string]string{"tmi-sent-ts": "3000"}},
+	}
+	missed := hasherino.MissedMessages(history, time.UnixMilli(2000))
+	if len(missed) != 1 || missed[0].Text != "during" {
+		t.Errorf("expected only the chat message after the disconnect, got %v", missed)
+	}
+}
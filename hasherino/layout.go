@@ -0,0 +1,199 @@
+package hasherino
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type SplitDirection int
+
+const (
+	SplitNone       SplitDirection = iota
+	SplitHorizontal                // side by side
+	SplitVertical                  // one above the other
+)
+
+// Node of a page's layout, a channel when it's a leaf or two nodes split in a direction otherwise
+type LayoutNode struct {
+	Channel  string         `json:"channel,omitempty"`
+	Split    SplitDirection `json:"split,omitempty"`
+	Offset   float64        `json:"offset,omitempty"` // share of the first child
+	Children []*LayoutNode  `json:"children,omitempty"`
+}
+
+func (n *LayoutNode) IsLeaf() bool {
+	return n.Split == SplitNone
+}
+
+// Channels of the leaves, left to right and top to bottom
+func (n *LayoutNode) Channels() []string {
+	if n == nil {
+		return []string{}
+	}
+	if n.IsLeaf() {
+		return []string{n.Channel}
+	}
+	channels := []string{}
+	for _, child := range n.Children {
+		channels = append(channels, child.Channels()...)
+	}
+	return channels
+}
+
+func (n *LayoutNode) find(channel string) *LayoutNode {
+	if n == nil {
+		return nil
+	}
+	if n.IsLeaf() {
+		if n.Channel == channel {
+			return n
+		}
+		return nil
+	}
+	for _, child := range n.Children {
+		if found := child.find(channel); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Splits the leaf of channel in two, newChannel going before it (left or above) if before is set.
+// Returns false if channel isn't in the layout.
+func (n *LayoutNode) SplitLeaf(channel string, newChannel string, direction SplitDirection, before bool) bool {
+	leaf := n.find(channel)
+	if leaf == nil || direction == SplitNone {
+		return false
+	}
+	children := []*LayoutNode{{Channel: channel}, {Channel: newChannel}}
+	if before {
+		children[0], children[1] = children[1], children[0]
+	}
+	*leaf = LayoutNode{Split: direction, Offset: 0.5, Children: children}
+	return true
+}
+
+// Removes the leaf of channel, its sibling taking the place of their split. Returns the new root,
+// nil once the last channel is removed.
+func (n *LayoutNode) Remove(channel string) *LayoutNode {
+	if n == nil {
+		return nil
+	}
+	if n.IsLeaf() {
+		if n.Channel == channel {
+			return nil
+		}
+		return n
+	}
+	for i, child := range n.Children {
+		if remaining := child.Remove(channel); remaining != child {
+			if remaining == nil {
+				return n.Children[1-i]
+			}
+			n.Children[i] = remaining
+			return n
+		}
+	}
+	return n
+}
+
+// Moves the leaf of channel next to the one of target, see SplitLeaf. Returns the new root.
+func (n *LayoutNode) Move(channel string, target string, direction SplitDirection, before bool) *LayoutNode {
+	if channel == target || n.find(channel) == nil || n.find(target) == nil {
+		return n
+	}
+	root := n.Remove(channel)
+	root.SplitLeaf(target, channel, direction, before)
+	return root
+}
+
+// Whether every leaf has a channel, each only once, and every split has two children
+func (n *LayoutNode) Validate() error {
+	seen := make(map[string]bool)
+	var validate func(node *LayoutNode) error
+	validate = func(node *LayoutNode) error {
+		if node.IsLeaf() {
+			if node.Channel == "" {
+				return errors.New("layout has a split without a channel")
+			}
+			if seen[node.Channel] {
+				return errors.New(node.Channel + " is in the layout more than once")
+			}
+			seen[node.Channel] = true
+			return nil
+		}
+		if len(node.Children) != 2 {
+			return errors.New("layout splits need two sides")
+		}
+		for _, child := range node.Children {
+			if err := validate(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if n == nil {
+		return errors.New("empty layout")
+	}
+	return validate(n)
+}
+
+// Tab of the main window, showing one or more channels laid out in splits
+type Page struct {
+	Id       uint `gorm:"primaryKey"`
+	Position int
+	Selected bool
+	Root     *LayoutNode `gorm:"serializer:json"`
+}
+
+func (p *Page) Name() string {
+	return strings.Join(p.Root.Channels(), ", ")
+}
+
+// A channel shown in a window of its own instead of its split, placed and sized as it was left
+type PopOut struct {
+	Channel      string `gorm:"primaryKey"`
+	Width        float32
+	Height       float32
+	X, Y         int  // screen coordinates
+	Placed       bool // X and Y were saved, windows are left to the window manager until then
+	Overlay      bool // only the messages, transparent and above other windows, to put chat over a game
+	ClickThrough bool // overlay lets clicks through to the window behind it
+	Open         bool // reopened on startup
+}
+
+// Gives every tab saved before pages existed a page of its own, keeping the selected tab from the
+// column tabs had for it. Does nothing once there are pages, so it's safe to run on every start.
+func MigrateTabsToPages(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var pages int64
+		if result := tx.Model(&Page{}).Count(&pages); result.Error != nil || pages > 0 {
+			return result.Error
+		}
+		selected := []string{}
+		hasSelected := tx.Migrator().HasColumn(&Tab{}, "selected")
+		if hasSelected {
+			result := tx.Model(&Tab{}).Where("selected = ?", true).Pluck("id", &selected)
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+		tabs := []*Tab{}
+		if result := tx.Find(&tabs); result.Error != nil {
+			return result.Error
+		}
+		for i, tab := range tabs {
+			page := &Page{Position: i, Selected: slices.Contains(selected, tab.Id), Root: &LayoutNode{Channel: tab.Login}}
+			if result := tx.Create(page); result.Error != nil {
+				return result.Error
+			}
+		}
+		if hasSelected {
+			return tx.Migrator().DropColumn(&Tab{}, "selected")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,124 @@
+package hasherino_test
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+func TestLayoutNode(t *testing.T) {
+	root := &hasherino.LayoutNode{Channel: "foo"}
+	if !root.SplitLeaf("foo", "bar", hasherino.SplitHorizontal, false) {
+		t.Fatal("expected foo to be split")
+	}
+	if !root.SplitLeaf("bar", "baz", hasherino.SplitVertical, true) {
+		t.Fatal("expected bar to be split")
+	}
+	if root.SplitLeaf("missing", "qux", hasherino.SplitVertical, false) {
+		t.Error("expected splitting a missing channel to fail")
+	}
+	if channels := root.Channels(); !slices.Equal(channels, []string{"foo", "baz", "bar"}) {
+		t.Errorf("unexpected channels %v", channels)
+	}
+	if err := root.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	// foo goes below bar, baz takes the place of the split it was in
+	root = root.Move("foo", "bar", hasherino.SplitVertical, false)
+	if channels := root.Channels(); !slices.Equal(channels, []string{"baz", "bar", "foo"}) {
+		t.Errorf("unexpected channels after moving %v", channels)
+	}
+	if root.Split != hasherino.SplitVertical || root.Children[1].Split != hasherino.SplitVertical {
+		t.Errorf("unexpected splits after moving %+v", root)
+	}
+
+	root = root.Remove("bar")
+	root = root.Remove("baz")
+	if !root.IsLeaf() || root.Channel != "foo" {
+		t.Errorf("expected only foo to be left, got %+v", root)
+	}
+	if root.Remove("foo") != nil {
+		t.Error("expected removing the last channel to empty the layout")
+	}
+
+	duplicate := &hasherino.LayoutNode{Split: hasherino.SplitHorizontal, Children: []*hasherino.LayoutNode{
+		{Channel: "foo"}, {Channel: "foo"},
+	}}
+	if duplicate.Validate() == nil {
+		t.Error("expected a channel shown twice to be invalid")
+	}
+}
+
+func TestPagePersistence(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "gorm.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&hasherino.Page{}); err != nil {
+		t.Fatal(err)
+	}
+	root := &hasherino.LayoutNode{Channel: "foo"}
+	root.SplitLeaf("foo", "bar", hasherino.SplitVertical, false)
+	if err := db.Create(&hasherino.Page{Root: root}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	page := &hasherino.Page{}
+	if err := db.Take(page).Error; err != nil {
+		t.Fatal(err)
+	}
+	if page.Name() != "foo, bar" || page.Root.Split != hasherino.SplitVertical || page.Root.Offset != 0.5 {
+		t.Errorf("unexpected page %+v", page.Root)
+	}
+}
+
+// Tabs as they were saved before pages, with the selection in a column of their own
+type legacyTab struct {
+	Id       string `gorm:"primaryKey"`
+	Login    string
+	Selected bool
+}
+
+func (legacyTab) TableName() string {
+	return "tabs"
+}
+
+func TestMigrateTabsToPages(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "gorm.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&legacyTab{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Create([]*legacyTab{{Id: "1", Login: "foo"}, {Id: "2", Login: "bar", Selected: true}})
+	if err := db.AutoMigrate(&hasherino.Tab{}, &hasherino.Page{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Running again, like every start does, keeps the pages there are
+	for i := 0; i < 2; i++ {
+		if err := hasherino.MigrateTabsToPages(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pages := []*hasherino.Page{}
+	if err := db.Order("position").Find(&pages).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("expected a page per tab, got %d", len(pages))
+	}
+	if pages[0].Name() != "foo" || pages[0].Selected || pages[1].Name() != "bar" || !pages[1].Selected {
+		t.Errorf("expected bar's page to be selected, got %+v %+v", pages[0], pages[1])
+	}
+	if db.Migrator().HasColumn(&hasherino.Tab{}, "selected") {
+		t.Error("expected the selected column to be dropped")
+	}
+}
@@ -0,0 +1,205 @@
+package hasherino
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const logDateFormat = "2006-01-02"
+
+// Which channels get logged and how, see AppSettings
+type ChatLogOptions struct {
+	Channels      map[string]bool
+	Raw           bool  // also write the raw IRC lines to <date>.irc.log
+	MaxSize       int64 // bytes per file before moving to <date>.1.log, 0 for no limit
+	RetentionDays int   // days log files are kept, 0 to keep them forever
+}
+
+type logFile struct {
+	file *os.File
+	date string
+	part int
+	size int64
+}
+
+// Writes chat messages to <folder>/<channel>/<date>.log in chatterino's format,
+// one file per day and channel
+type ChatLogger struct {
+	folder  string
+	mutex   sync.Mutex
+	options ChatLogOptions
+	files   map[string]*logFile // by channel, plus ".irc" for raw logs
+}
+
+func NewChatLogger(folder string) *ChatLogger {
+	return &ChatLogger{folder: folder, files: make(map[string]*logFile)}
+}
+
+func (l *ChatLogger) Configure(options ChatLogOptions) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.options = options
+	for key, file := range l.files {
+		channel := strings.TrimSuffix(key, ".irc")
+		if !options.Channels[channel] || (key != channel && !options.Raw) {
+			file.file.Close()
+			delete(l.files, key)
+		}
+	}
+}
+
+func (l *ChatLogger) fileName(channel string, suffix string, date string, part int) string {
+	name := date + suffix
+	if part > 0 {
+		name += "." + strconv.Itoa(part)
+	}
+	return filepath.Join(l.folder, channel, name+".log")
+}
+
+// Opens the file line goes to, rotating when the date changes or the size cap is reached
+func (l *ChatLogger) open(channel string, suffix string, at time.Time, length int) (*logFile, error) {
+	key := channel + suffix
+	date := at.Format(logDateFormat)
+	current, ok := l.files[key]
+	if ok && current.date == date && (l.options.MaxSize == 0 || current.size+int64(length) <= l.options.MaxSize) {
+		return current, nil
+	}
+
+	part := 0
+	if ok {
+		current.file.Close()
+		delete(l.files, key)
+		if current.date == date {
+			part = current.part + 1
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(l.folder, channel), 0o755); err != nil {
+		return nil, err
+	}
+	for {
+		name := l.fileName(channel, suffix, date, part)
+		info, err := os.Stat(name)
+		// Files from earlier runs are appended to until they're full
+		if err == nil && l.options.MaxSize > 0 && info.Size()+int64(length) > l.options.MaxSize {
+			part++
+			continue
+		}
+		file, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		opened := &logFile{file: file, date: date, part: part}
+		if info != nil {
+			opened.size = info.Size()
+		}
+		l.files[key] = opened
+		if opened.size == 0 && suffix == "" {
+			l.writeLine(opened, "# Start logging at "+at.Format("2006-01-02 15:04:05 MST"))
+		}
+		return opened, nil
+	}
+}
+
+func (l *ChatLogger) writeLine(file *logFile, line string) error {
+	n, err := file.file.WriteString(line + "\n")
+	file.size += int64(n)
+	return err
+}
+
+func (l *ChatLogger) write(channel string, suffix string, at time.Time, line string) error {
+	file, err := l.open(channel, suffix, at, len(line)+1)
+	if err != nil {
+		return err
+	}
+	return l.writeLine(file, line)
+}
+
+// Whether channel's messages are logged
+func (l *ChatLogger) Logs(channel string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.options.Channels[channel]
+}
+
+// Logs msg if its channel is logged, as "[15:04:05] Display (login): text"
+func (l *ChatLogger) LogMessage(msg *ChatMessage) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if msg.Command != "PRIVMSG" || !l.options.Channels[msg.Channel] {
+		return nil
+	}
+	at := msg.Time()
+	if at.IsZero() {
+		at = time.Now()
+	}
+	at = at.Local()
+	displayName := msg.Tags["display-name"]
+	if displayName == "" {
+		displayName = msg.Author
+	}
+	line := "[" + at.Format("15:04:05") + "] " + displayName + " (" + msg.Author + "): " + msg.Text
+	return l.write(msg.Channel, "", at, line)
+}
+
+// Logs an IRC line as received if raw logging is enabled for channel
+func (l *ChatLogger) LogRaw(channel string, line string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !l.options.Raw || !l.options.Channels[channel] {
+		return nil
+	}
+	return l.write(channel, ".irc", time.Now(), strings.TrimRight(line, "\r\n"))
+}
+
+// Deletes the log files older than the retention days
+func (l *ChatLogger) Prune(now time.Time) error {
+	l.mutex.Lock()
+	retention := l.options.RetentionDays
+	l.mutex.Unlock()
+	if retention <= 0 {
+		return nil
+	}
+	cutoff := now.AddDate(0, 0, -retention).Format(logDateFormat)
+
+	channels, err := os.ReadDir(l.folder)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, channel := range channels {
+		if !channel.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(l.folder, channel.Name()))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			name := file.Name()
+			if len(name) < len(logDateFormat) || !strings.HasSuffix(name, ".log") {
+				continue
+			}
+			// Dates sort as strings
+			if date := name[:len(logDateFormat)]; date < cutoff {
+				if err := os.Remove(filepath.Join(l.folder, channel.Name(), name)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (l *ChatLogger) Close() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for key, file := range l.files {
+		file.file.Close()
+		delete(l.files, key)
+	}
+}
@@ -0,0 +1,68 @@
+package hasherino_test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+func logMessage(t *testing.T, logger *hasherino.ChatLogger, channel string, at time.Time, text string) {
+	t.Helper()
+	err := logger.LogMessage(&hasherino.ChatMessage{
+		Channel: channel,
+		Command: "PRIVMSG",
+		Author:  "foo",
+		Text:    text,
+		Tags:    map[string]string{"display-name": "Foo", "tmi-sent-ts": strconv.FormatInt(at.UnixMilli(), 10)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChatLogger(t *testing.T) {
+	folder := t.TempDir()
+	logger := hasherino.NewChatLogger(folder)
+	defer logger.Close()
+	logger.Configure(hasherino.ChatLogOptions{Channels: map[string]bool{"bar": true}, MaxSize: 100})
+
+	day := time.Date(2024, 5, 1, 12, 30, 15, 0, time.Local)
+	logMessage(t, logger, "bar", day, "hello")
+	logMessage(t, logger, "other", day, "not logged")
+	logMessage(t, logger, "bar", day.Add(24*time.Hour), "next day")
+
+	content, err := os.ReadFile(filepath.Join(folder, "bar", "2024-05-01.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "# Start logging at 2024-05-01 12:30:15") || lines[1] != "[12:30:15] Foo (foo): hello" {
+		t.Errorf("unexpected log %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(folder, "bar", "2024-05-02.log")); err != nil {
+		t.Error("expected a file for the next day:", err)
+	}
+	if _, err := os.Stat(filepath.Join(folder, "other")); !os.IsNotExist(err) {
+		t.Error("expected channels without logging to have no logs")
+	}
+
+	// Past the size cap the day continues in another file
+	logMessage(t, logger, "bar", day, strings.Repeat("a", 60))
+	if _, err := os.Stat(filepath.Join(folder, "bar", "2024-05-01.1.log")); err != nil {
+		t.Error("expected the log to rotate past the size cap:", err)
+	}
+
+	logger.Configure(hasherino.ChatLogOptions{Channels: map[string]bool{"bar": true}, RetentionDays: 7})
+	if err := logger.Prune(day.AddDate(0, 0, 8)); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := os.ReadDir(filepath.Join(folder, "bar"))
+	if len(files) != 1 || files[0].Name() != "2024-05-02.log" {
+		t.Errorf("expected only the newest log to be kept, got %v", files)
+	}
+}
@@ -2,6 +2,11 @@ package hasherino
 
 import (
 	"errors"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -15,18 +20,121 @@ type Account struct {
 	Token       string // TODO: enable db encryption to hide token
 }
 
+// A joined channel and its settings, shown in a split of one of the Pages
 type Tab struct {
 	Id          string `gorm:"primaryKey"`
 	Login       string
 	DisplayName string
-	Selected    bool
+
+	// Filters applied to the tab's incoming messages
+	OnlySubscribers bool
+	OnlyLinks       bool
+	HideCommands    bool // messages starting with !
+
+	Logging bool // write the channel's messages to the logs folder
 }
 
 // Single row table for global settings
 type AppSettings struct {
 	gorm.Model
-	ChatMessageLimit int // Maximum amount of messages in a single chat
-	ChatHistory      bool
+	ChatMessageLimit int      // Maximum amount of messages in a single chat
+	ChatHistory      bool     // Load history from a recent-messages service
+	ChatHistoryUrl   string   // Base url of the recent-messages service, DefaultRecentMessagesUrl if empty
+	TwemojiRendering bool     // Show the emoji picker's emoji as Twemoji images instead of the system font
+	EmojiSkinTone    SkinTone // Skin tone used by the emoji picker
+
+	// Chat logs of the channels with logging enabled, see ChatLogOptions
+	ChatLogRaw           bool
+	ChatLogMaxSizeMB     int
+	ChatLogRetentionDays int
+	ChatHistoryFromLogs  bool // Load history from the logs when the recent-messages service can't
+}
+
+// How many times a word was sent in a channel, used to rank tab completions there
+type CompletionUsage struct {
+	Channel string `gorm:"primaryKey"`
+	Word    string `gorm:"primaryKey"`
+	Uses    int
+}
+
+// Whisper sent or received by one of the accounts
+type Whisper struct {
+	gorm.Model
+	AccountId   string `gorm:"index:idx_whisper_conversation"`
+	UserId      string `gorm:"index:idx_whisper_conversation"` // the other user in the conversation
+	Login       string
+	DisplayName string
+	Incoming    bool
+	Text        string
+}
+
+// Latest whisper with each user
+type WhisperConversation struct {
+	UserId      string
+	Login       string
+	DisplayName string
+}
+
+// User whose messages are hidden. Synced ones mirror the active account's twitch blocks.
+type IgnoredUser struct {
+	Login  string `gorm:"primaryKey"`
+	UserId string
+	Synced bool
+}
+
+// Phrase hiding the messages containing it, or replaced with Replacement if Replace is set
+type IgnoredPhrase struct {
+	Id            uint `gorm:"primaryKey"`
+	Pattern       string
+	Regex         bool
+	CaseSensitive bool
+	Replace       bool
+	Replacement   string
+}
+
+type HighlightKind int64
+
+const (
+	HighlightMention      HighlightKind = iota // the active account's login, pattern is unused
+	HighlightPhrase                            // text containing pattern
+	HighlightRegex                             // text matching pattern as a regular expression
+	HighlightUser                              // messages by the login in pattern
+	HighlightBadge                             // messages by users with the badge named pattern, e.g. vip
+	HighlightFirstMessage                      // first message of a user in the channel, pattern is unused
+)
+
+var HighlightKinds = []HighlightKind{
+	HighlightMention, HighlightPhrase, HighlightRegex, HighlightUser, HighlightBadge, HighlightFirstMessage,
+}
+
+func (k HighlightKind) String() string {
+	switch k {
+	case HighlightMention:
+		return "Mention"
+	case HighlightPhrase:
+		return "Phrase"
+	case HighlightRegex:
+		return "Regex"
+	case HighlightUser:
+		return "User"
+	case HighlightBadge:
+		return "Badge"
+	case HighlightFirstMessage:
+		return "First message"
+	default:
+		return "unknown"
+	}
+}
+
+// Rule marking the messages it matches, checked in id order
+type HighlightRule struct {
+	Id            uint `gorm:"primaryKey"`
+	Kind          HighlightKind
+	Pattern       string
+	CaseSensitive bool
+	Color         string // #rrggbb
+	Sound         bool
+	Enabled       bool
 }
 
 // --- tempDB models ---
@@ -37,6 +145,17 @@ const (
 	SevenTV
 )
 
+func (s EmoteSourceEnum) String() string {
+	switch s {
+	case Twitch:
+		return "twitch"
+	case SevenTV:
+		return "7tv"
+	default:
+		return "unknown"
+	}
+}
+
 // Handles tab data that is not persisted
 type TempTab struct {
 	Id        string     `gorm:"primaryKey"`
@@ -51,15 +170,23 @@ type ChatUser struct {
 	Emotes      []Emote   `gorm:"foreignKey:OwnerID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE"`
 }
 
+// A chatter in a channel
 type ChatUserTempTab struct {
 	ChatUserId string `gorm:"primaryKey"`
 	TempTabId  string `gorm:"primaryKey"`
+	Badges     string // badges tag of the user's last message, e.g. "moderator/1,subscriber/12"
+	LastSeen   time.Time
+}
+
+// Same table as the many2many relation between ChatUser and TempTab
+func (ChatUserTempTab) TableName() string {
+	return "chat_user_temp_tab"
 }
 
 // When a TempTab gets deleted, delete all orphaned ChatUsers
 func (c *ChatUserTempTab) AfterDelete(tx *gorm.DB) (err error) {
 	var count int64
-	if err := tx.Model(&ChatUserTempTab{}).Where("ChatUserId = ?", c.ChatUserId).Count(&count).Error; err != nil {
+	if err := tx.Model(&ChatUserTempTab{}).Where("chat_user_id = ?", c.ChatUserId).Count(&count).Error; err != nil {
 		return err
 	}
 	if count == 0 {
@@ -70,6 +197,103 @@ func (c *ChatUserTempTab) AfterDelete(tx *gorm.DB) (err error) {
 	return nil
 }
 
+// A badge version from the global or a channel's badge sets
+type ChatBadge struct {
+	SetId     string `gorm:"primaryKey"`
+	Version   string `gorm:"primaryKey"`
+	ChannelID string `gorm:"primaryKey"` // empty for global badges
+	Title     string
+	ImageUrl  string // 2x image
+}
+
+func (b *ChatBadge) GetUrl() (string, error) {
+	return b.ImageUrl, nil
+}
+
+// Badge urls look like https://static-cdn.jtvnw.net/badges/v1/<uuid>/2
+func (b *ChatBadge) GetCacheKey() ImageCacheKey {
+	return ImageCacheKey{
+		Provider: "twitch-badge",
+		Id:       path.Base(path.Dir(b.ImageUrl)),
+		Scale:    "2x",
+		Format:   "png",
+	}
+}
+
+// Picks the subscriber badge version to show for months subscribed from the versions a channel
+// has, keeping the tier of version. Twitch numbers them by months, with tier 2 and 3 badges
+// starting at 2000 and 3000.
+func SubscriberBadgeVersion(available []string, version string, months int) (string, bool) {
+	if slices.Contains(available, version) {
+		return version, true
+	}
+	requested, err := strconv.Atoi(version)
+	if err != nil {
+		return "", false
+	}
+	tier := requested / 1000 * 1000
+	if months <= 0 {
+		months = requested % 1000
+	}
+
+	best := -1
+	for _, v := range available {
+		n, err := strconv.Atoi(v)
+		if err != nil || n/1000*1000 != tier || n%1000 > months {
+			continue
+		}
+		if n > best {
+			best = n
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	return strconv.Itoa(best), true
+}
+
+// Profile picture of a twitch user, cached like emotes
+type ProfileImage struct {
+	UserId string
+	Url    string
+}
+
+func (p *ProfileImage) GetUrl() (string, error) {
+	if p.Url == "" {
+		return "", errors.New("user has no profile image")
+	}
+	return p.Url, nil
+}
+
+// Keyed by the url's file name, which changes along with the picture
+func (p *ProfileImage) GetCacheKey() ImageCacheKey {
+	name := path.Base(p.Url)
+	ext := path.Ext(name)
+	return ImageCacheKey{
+		Provider: "twitch-avatar",
+		Id:       p.UserId + "-" + strings.TrimSuffix(name, ext),
+		Scale:    "300x300",
+		Format:   strings.TrimPrefix(ext, "."),
+	}
+}
+
+// A ChatUser as seen in a single channel
+type Chatter struct {
+	Id          string
+	Login       string
+	DisplayName string
+	Badges      string
+	LastSeen    time.Time
+}
+
+// Name to show, the login when the display name is localized
+func (c *Chatter) Name() string {
+	if c.DisplayName == "" || !strings.EqualFold(c.DisplayName, c.Login) {
+		return c.Login
+	}
+	return c.DisplayName
+}
+
 type Emote struct {
 	Id        string          `gorm:"primaryKey"`
 	Source    EmoteSourceEnum `gorm:"primaryKey"`
@@ -78,7 +302,6 @@ type Emote struct {
 	ChannelID *string   `gorm:"primaryKey"`                       // if a channel is set, only renders in that channel
 	OwnerID   string    `gorm:"primaryKey;index"`                 // Foreign key field
 	Owner     *ChatUser `gorm:"foreignKey:OwnerID;references:Id"` // if an owner is set, only renders when the message sender is the owner
-	TempFile  string
 }
 
 func (e *Emote) GetUrl() (string, error) {
@@ -94,16 +317,85 @@ func (e *Emote) GetUrl() (string, error) {
 	return result + e.GetUrlExtension(), nil
 }
 
+// Key used to store the emote's image in the image cache
+func (e *Emote) GetCacheKey() ImageCacheKey {
+	return ImageCacheKey{
+		Provider: e.Source.String(),
+		Id:       e.Id,
+		Scale:    "2x",
+		Format:   e.GetFormat(),
+	}
+}
+
+func (e *Emote) GetFormat() string {
+	extension := e.GetUrlExtension()
+	if extension != "" {
+		return extension[1:]
+	}
+	if e.Animated {
+		return "gif"
+	}
+	return "png"
+}
+
 func (e *Emote) GetUrlExtension() string {
 	switch e.Source {
 	case Twitch:
 		return ""
 	case SevenTV:
-		if e.Animated {
-			return ".gif"
-		}
 		return ".webp"
 	default:
 		return ".webp"
 	}
 }
+
+type Emoji struct {
+	Emoji           string `gorm:"primaryKey"`
+	Name            string
+	Slug            string `gorm:"index"` // used as the :shortcode:
+	Group           string
+	SkinToneSupport bool
+	Position        int // order in the unicode emoji list
+}
+
+// Returns the emoji with a skin tone modifier, if the emoji supports it
+func (e *Emoji) WithSkinTone(tone SkinTone) string {
+	if !e.SkinToneSupport || tone == SkinToneDefault {
+		return e.Emoji
+	}
+	runes := []rune(e.Emoji)
+	// The modifier goes after the base character, replacing its variation selector
+	rest := runes[1:]
+	if len(rest) > 0 && rest[0] == variationSelector16 {
+		rest = rest[1:]
+	}
+	return string(runes[0]) + string(skinToneModifiers[tone]) + string(rest)
+}
+
+// Twemoji image for the emoji, for a consistent look across platforms
+func (e *Emoji) GetUrl() (string, error) {
+	return "https://cdn.jsdelivr.net/gh/jdecked/twemoji@15.0.3/assets/72x72/" + e.twemojiCode() + ".png", nil
+}
+
+func (e *Emoji) GetCacheKey() ImageCacheKey {
+	return ImageCacheKey{
+		Provider: "twemoji",
+		Id:       e.twemojiCode(),
+		Scale:    "72x72",
+		Format:   "png",
+	}
+}
+
+// Twemoji names files after the code points, dropping variation selectors unless it's a ZWJ sequence
+func (e *Emoji) twemojiCode() string {
+	runes := []rune(e.Emoji)
+	hasZWJ := strings.ContainsRune(e.Emoji, zeroWidthJoiner)
+	codes := []string{}
+	for _, r := range runes {
+		if r == variationSelector16 && !hasZWJ {
+			continue
+		}
+		codes = append(codes, strconv.FormatInt(int64(r), 16))
+	}
+	return strings.Join(codes, "-")
+}
@@ -1,7 +1,9 @@
 package hasherino
 
 import (
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/irc.v4"
 )
@@ -11,6 +13,21 @@ type ChatMessage struct {
 	Command string
 	Author  string
 	Text    string
+	Tags    map[string]string // IRCv3 tags like user-id, room-id and display-name
+
+	Highlight *Highlight // set by the controller when a highlight rule matches
+}
+
+// Command of the lines hasherino adds to a chat itself, like the marker after a reconnect
+const SystemCommand = "HASHERINO"
+
+func NewSystemMessage(channel string, text string) ChatMessage {
+	return ChatMessage{
+		Channel: channel,
+		Command: SystemCommand,
+		Text:    text,
+		Tags:    map[string]string{"tmi-sent-ts": strconv.FormatInt(time.Now().UnixMilli(), 10)},
+	}
 }
 
 func ParseMessage(message string) (*ChatMessage, error) {
@@ -35,6 +52,72 @@ func ParseMessage(message string) (*ChatMessage, error) {
 		Command: msg.Command,
 		Author:  msg.Name,
 		Text:    paramsText,
+		Tags:    msg.Tags,
 	}, nil
 
 }
+
+// Display name of the author, the login when the display name is localized
+func (m *ChatMessage) AuthorName() string {
+	displayName := m.Tags["display-name"]
+	if displayName == "" || (m.Author != "" && !strings.EqualFold(displayName, m.Author)) {
+		return m.Author
+	}
+	return displayName
+}
+
+func (m *ChatMessage) Id() string {
+	return m.Tags["id"]
+}
+
+// Id of the message this one replies to, empty if it isn't a reply
+func (m *ChatMessage) ReplyParentId() string {
+	return m.Tags["reply-parent-msg-id"]
+}
+
+// Id of the first message of the reply thread this message is in, its own id if it isn't a reply
+func (m *ChatMessage) ThreadId() string {
+	if id := m.Tags["reply-thread-parent-msg-id"]; id != "" {
+		return id
+	}
+	if id := m.ReplyParentId(); id != "" {
+		return id
+	}
+	return m.Id()
+}
+
+// Text without the @mention twitch adds at the start of replies
+func (m *ChatMessage) ReplyText() string {
+	login := m.Tags["reply-parent-user-login"]
+	if login == "" {
+		return m.Text
+	}
+	return strings.TrimPrefix(m.Text, "@"+login+" ")
+}
+
+// When twitch received the message, zero if unknown
+func (m *ChatMessage) Time() time.Time {
+	ms, err := strconv.ParseInt(m.Tags["tmi-sent-ts"], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+type Badge struct {
+	Name    string
+	Version string
+}
+
+// Parses a badges or badge-info tag, e.g. "moderator/1,subscriber/12"
+func ParseBadges(tag string) []Badge {
+	badges := []Badge{}
+	for _, badge := range strings.Split(tag, ",") {
+		name, version, _ := strings.Cut(badge, "/")
+		if name == "" {
+			continue
+		}
+		badges = append(badges, Badge{Name: name, Version: version})
+	}
+	return badges
+}
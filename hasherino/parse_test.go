@@ -0,0 +1,70 @@
+package hasherino_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+func TestParseMessageTags(t *testing.T) {
+	msg, err := hasherino.ParseMessage("@badges=moderator/1,subscriber/12;display-name=Foo;room-id=1;user-id=2 " +
+		":foo!foo@foo.tmi.twitch.tv PRIVMSG #bar :hello there")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Channel != "bar" || msg.Author != "foo" || msg.Text != "hello there" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	if msg.Tags["user-id"] != "2" || msg.Tags["room-id"] != "1" {
+		t.Errorf("unexpected tags %v", msg.Tags)
+	}
+
+	expected := []hasherino.Badge{{Name: "moderator", Version: "1"}, {Name: "subscriber", Version: "12"}}
+	if badges := hasherino.ParseBadges(msg.Tags["badges"]); !reflect.DeepEqual(badges, expected) {
+		t.Errorf("expected %v, got %v", expected, badges)
+	}
+	if badges := hasherino.ParseBadges(""); len(badges) != 0 {
+		t.Errorf("expected no badges, got %v", badges)
+	}
+}
+
+func TestSubscriberBadgeVersion(t *testing.T) {
+	available := []string{"0", "3", "6", "12", "2000", "2012", "3000"}
+	cases := []struct {
+		version  string
+		months   int
+		expected string
+		ok       bool
+	}{
+		{"6", 7, "6", true},        // channel has the exact version
+		{"9", 10, "6", true},       // missing version, highest below the months
+		{"24", 30, "12", true},     // past the last custom badge
+		{"2024", 26, "2012", true}, // tiers are kept apart
+		{"3012", 14, "3000", true},
+		{"4000", 1, "", false},
+	}
+	for _, c := range cases {
+		got, ok := hasherino.SubscriberBadgeVersion(available, c.version, c.months)
+		if got != c.expected || ok != c.ok {
+			t.Errorf("version %s, %d months: expected %s %v, got %s %v", c.version, c.months, c.expected, c.ok, got, ok)
+		}
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	msg, err := hasherino.ParseMessage(`@id=3;reply-parent-msg-id=2;reply-parent-user-login=foo;` +
+		`reply-parent-msg-body=hello\sthere;reply-thread-parent-msg-id=1 :bar!bar@bar.tmi.twitch.tv PRIVMSG #baz :@foo hi`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Id() != "3" || msg.ReplyParentId() != "2" || msg.ThreadId() != "1" {
+		t.Errorf("unexpected ids %s %s %s", msg.Id(), msg.ReplyParentId(), msg.ThreadId())
+	}
+	if msg.ReplyText() != "hi" {
+		t.Errorf("expected the parent mention to be stripped, got %q", msg.ReplyText())
+	}
+	if msg.Tags["reply-parent-msg-body"] != "hello there" {
+		t.Errorf("expected unescaped parent body, got %q", msg.Tags["reply-parent-msg-body"])
+	}
+}
@@ -0,0 +1,283 @@
+package hasherino
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const searchDateFormat = "2006-01-02"
+
+// Chat message stored for searching, in search.db next to gorm.db
+type IndexedMessage struct {
+	Id          uint   `gorm:"primaryKey"`
+	Channel     string `gorm:"index"`
+	Author      string `gorm:"index"`
+	DisplayName string
+	MessageId   string
+	HasLink     bool
+	Subscriber  bool
+	SentAt      time.Time `gorm:"index"`
+	Text        string
+}
+
+// Rows of indexed_messages when the full-text index was last in sync with them. Builds without
+// full-text search store messages without indexing them, which changes the count or last id.
+type messageIndexState struct {
+	Id     uint `gorm:"primaryKey"`
+	Count  int64
+	LastId uint
+}
+
+// Parsed search, e.g. "from:foo in:bar has:link is:sub after:2024-01-01 before:2024-02-01 hello"
+type SearchQuery struct {
+	Terms      []string
+	From       string
+	In         string
+	HasLink    bool
+	Subscriber bool
+	After      time.Time
+	Before     time.Time
+}
+
+func ParseSearchQuery(text string) (*SearchQuery, error) {
+	query := &SearchQuery{}
+	for _, word := range strings.Fields(text) {
+		key, value, found := strings.Cut(word, ":")
+		if !found || value == "" {
+			query.Terms = append(query.Terms, word)
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "from":
+			query.From = strings.ToLower(strings.TrimPrefix(value, "@"))
+		case "in":
+			query.In = strings.ToLower(strings.TrimPrefix(value, "#"))
+		case "has":
+			if value != "link" {
+				return nil, errors.New("unknown filter has:" + value)
+			}
+			query.HasLink = true
+		case "is":
+			if value != "sub" {
+				return nil, errors.New("unknown filter is:" + value)
+			}
+			query.Subscriber = true
+		case "after", "before":
+			date, err := time.ParseInLocation(searchDateFormat, value, time.Local)
+			if err != nil {
+				return nil, errors.New("dates have to look like " + searchDateFormat)
+			}
+			if strings.EqualFold(key, "after") {
+				query.After = date
+			} else {
+				query.Before = date
+			}
+		default:
+			query.Terms = append(query.Terms, word)
+		}
+	}
+	return query, nil
+}
+
+// Stores messages and searches them, with sqlite's full-text search when it's compiled in
+// (the sqlite_fts5 build tag) and a slower LIKE search otherwise
+type MessageIndex struct {
+	db  *gorm.DB
+	fts bool
+}
+
+func OpenMessageIndex(path string) (*MessageIndex, error) {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.AutoMigrate(&IndexedMessage{}, &messageIndexState{}); err != nil {
+		return nil, err
+	}
+	index := &MessageIndex{db: db}
+	err = db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS indexed_messages_fts
+		USING fts5(text, content='indexed_messages', content_rowid='id')`).Error
+	if err != nil {
+		log.Println("Full-text search unavailable, falling back to LIKE:", err)
+		return index, nil
+	}
+	index.fts = true
+	err = db.Transaction(func(tx *gorm.DB) error {
+		current, err := indexState(tx)
+		if err != nil {
+			return err
+		}
+		saved := &messageIndexState{}
+		result := tx.Limit(1).Find(saved)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 && saved.Count == current.Count && saved.LastId == current.LastId {
+			return nil
+		}
+		err = tx.Exec("INSERT INTO indexed_messages_fts(indexed_messages_fts) VALUES ('rebuild')").Error
+		if err != nil {
+			return err
+		}
+		return tx.Save(current).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// Current row count and last id of indexed_messages
+func indexState(tx *gorm.DB) (*messageIndexState, error) {
+	state := &messageIndexState{}
+	err := tx.Model(&IndexedMessage{}).Select("COUNT(*) AS count, COALESCE(MAX(id), 0) AS last_id").Scan(state).Error
+	state.Id = 1
+	return state, err
+}
+
+func (i *MessageIndex) Add(msg *ChatMessage) error {
+	return i.AddAll([]*ChatMessage{msg})
+}
+
+// Stores the chat messages of messages in a single transaction, skipping the rest
+func (i *MessageIndex) AddAll(messages []*ChatMessage) error {
+	indexed := []*IndexedMessage{}
+	for _, msg := range messages {
+		if msg.Command != "PRIVMSG" {
+			continue
+		}
+		sentAt := msg.Time()
+		if sentAt.IsZero() {
+			sentAt = time.Now()
+		}
+		indexed = append(indexed, &IndexedMessage{
+			Channel:     msg.Channel,
+			Author:      msg.Author,
+			DisplayName: msg.AuthorName(),
+			MessageId:   msg.Id(),
+			HasLink:     linkRegex.MatchString(msg.Text),
+			Subscriber:  isSubscriber(msg),
+			SentAt:      sentAt,
+			Text:        msg.Text,
+		})
+	}
+	if len(indexed) == 0 {
+		return nil
+	}
+	return i.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(indexed).Error; err != nil {
+			return err
+		}
+		if !i.fts {
+			return nil
+		}
+		for _, message := range indexed {
+			err := tx.Exec("INSERT INTO indexed_messages_fts(rowid, text) VALUES (?, ?)", message.Id, message.Text).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Model(&messageIndexState{Id: 1}).Updates(map[string]any{
+			"count":   gorm.Expr("count + ?", len(indexed)),
+			"last_id": indexed[len(indexed)-1].Id,
+		}).Error
+	})
+}
+
+// Quotes term as an FTS5 string so its punctuation isn't read as query syntax
+func ftsTerm(term string) string {
+	return `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+}
+
+// Returns the newest messages matching query
+func (i *MessageIndex) Search(query *SearchQuery, limit int) ([]*IndexedMessage, error) {
+	tx := i.db.Model(&IndexedMessage{})
+	if len(query.Terms) > 0 {
+		if i.fts {
+			match := []string{}
+			for _, term := range query.Terms {
+				match = append(match, ftsTerm(term))
+			}
+			tx = tx.Where("id IN (SELECT rowid FROM indexed_messages_fts WHERE indexed_messages_fts MATCH ?)",
+				strings.Join(match, " "))
+		} else {
+			for _, term := range query.Terms {
+				tx = tx.Where("text LIKE ? ESCAPE '\\'", "%"+escapeLike(term)+"%")
+			}
+		}
+	}
+	if query.From != "" {
+		tx = tx.Where("author = ?", query.From)
+	}
+	if query.In != "" {
+		tx = tx.Where("channel = ?", query.In)
+	}
+	if query.HasLink {
+		tx = tx.Where("has_link = ?", true)
+	}
+	if query.Subscriber {
+		tx = tx.Where("subscriber = ?", true)
+	}
+	if !query.After.IsZero() {
+		tx = tx.Where("sent_at >= ?", query.After)
+	}
+	if !query.Before.IsZero() {
+		tx = tx.Where("sent_at < ?", query.Before)
+	}
+	messages := []*IndexedMessage{}
+	result := tx.Order("sent_at DESC").Limit(limit).Find(&messages)
+	return messages, result.Error
+}
+
+func escapeLike(term string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+}
+
+// Returns up to count messages of the same channel before and after message, oldest first
+func (i *MessageIndex) Context(message *IndexedMessage, count int) ([]*IndexedMessage, error) {
+	before := []*IndexedMessage{}
+	result := i.db.Where("channel = ? AND id < ?", message.Channel, message.Id).Order("id DESC").Limit(count).Find(&before)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	after := []*IndexedMessage{}
+	result = i.db.Where("channel = ? AND id > ?", message.Channel, message.Id).Order("id").Limit(count).Find(&after)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	messages := []*IndexedMessage{}
+	for j := len(before) - 1; j >= 0; j-- {
+		messages = append(messages, before[j])
+	}
+	messages = append(messages, message)
+	return append(messages, after...), nil
+}
+
+// Deletes the messages sent before cutoff
+func (i *MessageIndex) Prune(cutoff time.Time) error {
+	return i.db.Transaction(func(tx *gorm.DB) error {
+		if i.fts {
+			err := tx.Exec(`INSERT INTO indexed_messages_fts(indexed_messages_fts, rowid, text)
+				SELECT 'delete', id, text FROM indexed_messages WHERE sent_at < ?`, cutoff).Error
+			if err != nil {
+				return err
+			}
+		}
+		result := tx.Delete(&IndexedMessage{}, "sent_at < ?", cutoff)
+		if result.Error != nil || !i.fts {
+			return result.Error
+		}
+		state, err := indexState(tx)
+		if err != nil {
+			return err
+		}
+		return tx.Save(state).Error
+	})
+}
@@ -0,0 +1,164 @@
+package hasherino_test
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	query, err := hasherino.ParseSearchQuery("from:@Foo in:#bar has:link is:sub after:2024-01-01 hello world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.From != "foo" || query.In != "bar" || !query.HasLink || !query.Subscriber {
+		t.Errorf("unexpected query %+v", query)
+	}
+	if query.After.Format("2006-01-02") != "2024-01-01" || !query.Before.IsZero() {
+		t.Errorf("unexpected dates %v %v", query.After, query.Before)
+	}
+	if len(query.Terms) != 2 || query.Terms[0] != "hello" || query.Terms[1] != "world" {
+		t.Errorf("unexpected terms %v", query.Terms)
+	}
+	if _, err := hasherino.ParseSearchQuery("before:yesterday"); err == nil {
+		t.Error("expected an invalid date to fail")
+	}
+}
+
+func TestMessageIndex(t *testing.T) {
+	index, err := hasherino.OpenMessageIndex(filepath.Join(t.TempDir(), "search.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	messages := []struct {
+		channel string
+		author  string
+		badges  string
+		text    string
+	}{
+		{"bar", "foo", "", "hello world"},
+		{"bar", "baz", "subscriber/6", "Hello there https://example.com"},
+		{"qux", "foo", "", "50% off, hello"},
+		{"bar", "foo", "", "goodbye"},
+	}
+	for i, m := range messages {
+		err := index.Add(&hasherino.ChatMessage{
+			Channel: m.channel,
+			Command: "PRIVMSG",
+			Author:  m.author,
+			Text:    m.text,
+			Tags: map[string]string{
+				"id":          strconv.Itoa(i),
+				"badges":      m.badges,
+				"tmi-sent-ts": strconv.FormatInt(start.AddDate(0, 0, i).UnixMilli(), 10),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		query    string
+		expected []string
+	}{
+		{"hello", []string{"2", "1", "0"}},
+		{"hello from:foo", []string{"2", "0"}},
+		{"hello in:bar", []string{"1", "0"}},
+		{"has:link", []string{"1"}},
+		{"is:sub hello", []string{"1"}},
+		{"after:2024-01-02 before:2024-01-04", []string{"2", "1"}},
+		{"AFTER:2024-01-03", []string{"3", "2"}},
+		{"50%", []string{"2"}},
+		{"missing", []string{}},
+	}
+	for _, c := range cases {
+		query, err := hasherino.ParseSearchQuery(c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found, err := index.Search(query, 10)
+		if err != nil {
+			t.Fatalf("%s: %s", c.query, err)
+		}
+		ids := []string{}
+		for _, message := range found {
+			ids = append(ids, message.MessageId)
+		}
+		if len(ids) != len(c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.query, c.expected, ids)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != c.expected[i] {
+				t.Errorf("%s: expected %v, got %v", c.query, c.expected, ids)
+				break
+			}
+		}
+	}
+
+	query, _ := hasherino.ParseSearchQuery("goodbye")
+	found, _ := index.Search(query, 10)
+	context, err := index.Context(found[0], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// qux's message isn't part of bar's context
+	if len(context) != 2 || context[0].MessageId != "1" || context[1].MessageId != "3" {
+		t.Errorf("unexpected context %v", context)
+	}
+
+	if err := index.Prune(start.AddDate(0, 0, 3)); err != nil {
+		t.Fatal(err)
+	}
+	query, _ = hasherino.ParseSearchQuery("hello")
+	if found, _ := index.Search(query, 10); len(found) != 0 {
+		t.Errorf("expected pruned messages to be gone, got %d", len(found))
+	}
+}
+
+// Builds without full-text search store messages without indexing them, opening the index in a
+// build with it has to catch up
+func TestMessageIndexCatchesUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search.db")
+	index, err := hasherino.OpenMessageIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.Add(&hasherino.ChatMessage{Channel: "bar", Command: "PRIVMSG", Author: "foo", Text: "kept",
+		Tags: map[string]string{"id": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Create(&hasherino.IndexedMessage{Channel: "bar", Author: "foo", MessageId: "2", Text: "missed",
+		SentAt: time.Now()}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err = hasherino.OpenMessageIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, term := range []string{"kept", "missed"} {
+		query, _ := hasherino.ParseSearchQuery(term)
+		found, err := index.Search(query, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != 1 {
+			t.Errorf("%s: expected one message, got %d", term, len(found))
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package hasherino
+
+import (
+	"sync"
+)
+
+// A message of a MessageStore, with what's needed to render it resolved once
+type StoredMessage struct {
+	Message    ChatMessage
+	Badges     []*ChatBadge
+	Redemption *RedemptionEvent
+	Deleted    bool // removed by a moderator, kept so it can still be seen greyed out
+
+	seq int // position since the store was created, to index messages without searching
+}
+
+type MessageStoreChange int
+
+const (
+	MessageAdded MessageStoreChange = iota
+	MessageModified
+	MessagesDeleted
+)
+
+// Recent messages of a channel in a ring buffer, the oldest dropped once it's full
+type MessageStore struct {
+	mutex    sync.RWMutex
+	messages []*StoredMessage
+	start    int // index of the oldest message in messages
+	count    int
+	nextSeq  int
+	ids      map[string]*StoredMessage
+	onChange func(change MessageStoreChange)
+}
+
+func NewMessageStore(capacity int) *MessageStore {
+	return &MessageStore{
+		messages: make([]*StoredMessage, max(capacity, 1)),
+		ids:      make(map[string]*StoredMessage),
+	}
+}
+
+// Called after every change, outside of the store's lock
+func (s *MessageStore) SetOnChange(onChange func(change MessageStoreChange)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.onChange = onChange
+}
+
+func (s *MessageStore) notify(change MessageStoreChange) {
+	s.mutex.RLock()
+	onChange := s.onChange
+	s.mutex.RUnlock()
+	if onChange != nil {
+		onChange(change)
+	}
+}
+
+func (s *MessageStore) at(i int) *StoredMessage {
+	return s.messages[(s.start+i)%len(s.messages)]
+}
+
+// Keeps the newest capacity messages if there are more
+func (s *MessageStore) SetCapacity(capacity int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	capacity = max(capacity, 1)
+	if capacity == len(s.messages) {
+		return
+	}
+	messages := make([]*StoredMessage, capacity)
+	dropped := max(s.count-capacity, 0)
+	for i := 0; i < dropped; i++ {
+		s.forget(s.at(i))
+	}
+	for i := dropped; i < s.count; i++ {
+		messages[i-dropped] = s.at(i)
+	}
+	s.messages = messages
+	s.start = 0
+	s.count -= dropped
+}
+
+func (s *MessageStore) forget(message *StoredMessage) {
+	if id := message.Message.Id(); id != "" && s.ids[id] == message {
+		delete(s.ids, id)
+	}
+}
+
+func (s *MessageStore) Add(message *StoredMessage) {
+	s.mutex.Lock()
+	message.seq = s.nextSeq
+	s.nextSeq++
+	if s.count < len(s.messages) {
+		s.messages[(s.start+s.count)%len(s.messages)] = message
+		s.count++
+	} else {
+		s.forget(s.messages[s.start])
+		s.messages[s.start] = message
+		s.start = (s.start + 1) % len(s.messages)
+	}
+	if id := message.Message.Id(); id != "" {
+		s.ids[id] = message
+	}
+	s.mutex.Unlock()
+	s.notify(MessageAdded)
+}
+
+// Inserts older messages, like a channel's history, before the stored ones. Messages already stored
+// are skipped, and the oldest are dropped if they don't all fit.
+func (s *MessageStore) Prepend(older []*StoredMessage) {
+	s.mutex.Lock()
+	combined := []*StoredMessage{}
+	for _, message := range older {
+		if id := message.Message.Id(); id == "" || s.ids[id] == nil {
+			combined = append(combined, message)
+		}
+	}
+	added := len(combined)
+	for i := 0; i < s.count; i++ {
+		combined = append(combined, s.at(i))
+	}
+	dropped := max(len(combined)-len(s.messages), 0)
+	for _, message := range combined[:dropped] {
+		s.forget(message)
+	}
+	combined = combined[dropped:]
+	clear(s.messages)
+	copy(s.messages, combined)
+	s.start = 0
+	s.count = len(combined)
+	for _, message := range combined {
+		message.seq = s.nextSeq
+		s.nextSeq++
+		if id := message.Message.Id(); id != "" {
+			s.ids[id] = message
+		}
+	}
+	s.mutex.Unlock()
+	if added > 0 {
+		s.notify(MessageAdded)
+	}
+}
+
+// Adds message after the stored ones sent before it, for messages arriving late like the ones
+// missed while disconnected. Messages already stored are skipped, and the oldest is dropped if
+// the store is full.
+func (s *MessageStore) Insert(message *StoredMessage) {
+	s.mutex.Lock()
+	if id := message.Message.Id(); id != "" && s.ids[id] != nil {
+		s.mutex.Unlock()
+		return
+	}
+	sentAt := message.Message.Time()
+	position := s.count
+	for position > 0 && s.at(position-1).Message.Time().After(sentAt) {
+		position--
+	}
+	combined := make([]*StoredMessage, 0, s.count+1)
+	for i := 0; i < position; i++ {
+		combined = append(combined, s.at(i))
+	}
+	combined = append(combined, message)
+	for i := position; i < s.count; i++ {
+		combined = append(combined, s.at(i))
+	}
+	if len(combined) > len(s.messages) {
+		s.forget(combined[0])
+		combined = combined[1:]
+	}
+	clear(s.messages)
+	copy(s.messages, combined)
+	s.start = 0
+	s.count = len(combined)
+	for _, stored := range combined {
+		stored.seq = s.nextSeq
+		s.nextSeq++
+		if id := stored.Message.Id(); id != "" {
+			s.ids[id] = stored
+		}
+	}
+	s.mutex.Unlock()
+	s.notify(MessageAdded)
+}
+
+func (s *MessageStore) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.count
+}
+
+// Message i from the oldest, nil if the store no longer has that many
+func (s *MessageStore) At(i int) *StoredMessage {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if i < 0 || i >= s.count {
+		return nil
+	}
+	return s.at(i)
+}
+
+// Copy of every message, oldest first
+func (s *MessageStore) Messages() []*StoredMessage {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	messages := make([]*StoredMessage, s.count)
+	for i := range messages {
+		messages[i] = s.at(i)
+	}
+	return messages
+}
+
+func (s *MessageStore) Get(id string) *StoredMessage {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.ids[id]
+}
+
+// Position of the message with id from the oldest, -1 if it isn't stored
+func (s *MessageStore) IndexOf(id string) int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	message, ok := s.ids[id]
+	if !ok {
+		return -1
+	}
+	return message.seq - s.at(0).seq
+}
+
+// Messages sent by login, oldest first
+func (s *MessageStore) ByUser(login string) []*StoredMessage {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	messages := []*StoredMessage{}
+	for i := 0; i < s.count; i++ {
+		if message := s.at(i); message.Message.Author == login {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// Newest message among the last limit ones that matches, nil if none does
+func (s *MessageStore) FindRecent(limit int, match func(message *StoredMessage) bool) *StoredMessage {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for i := s.count - 1; i >= max(0, s.count-limit); i-- {
+		if message := s.at(i); match(message) {
+			return message
+		}
+	}
+	return nil
+}
+
+// Changes a stored message, e.g. to attach the redemption it came with
+func (s *MessageStore) Modify(message *StoredMessage, change func(message *StoredMessage)) {
+	s.mutex.Lock()
+	s.forget(message)
+	change(message)
+	if id := message.Message.Id(); id != "" && s.count > 0 && message.seq >= s.at(0).seq {
+		s.ids[id] = message
+	}
+	s.mutex.Unlock()
+	s.notify(MessageModified)
+}
+
+// Marks the messages of a timed out or banned user as deleted, every message if login is empty
+func (s *MessageStore) MarkUserDeleted(login string) int {
+	s.mutex.Lock()
+	deleted := 0
+	for i := 0; i < s.count; i++ {
+		if message := s.at(i); !message.Deleted && (login == "" || message.Message.Author == login) {
+			message.Deleted = true
+			deleted++
+		}
+	}
+	s.mutex.Unlock()
+	if deleted > 0 {
+		s.notify(MessagesDeleted)
+	}
+	return deleted
+}
+
+// Marks the message with id as deleted, returns false if it isn't stored
+func (s *MessageStore) MarkDeleted(id string) bool {
+	s.mutex.Lock()
+	message, ok := s.ids[id]
+	if ok {
+		message.Deleted = true
+	}
+	s.mutex.Unlock()
+	if ok {
+		s.notify(MessagesDeleted)
+	}
+	return ok
+}
@@ -0,0 +1,97 @@
+package hasherino_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+func storedMessage(id int, author string) *hasherino.StoredMessage {
+	return &hasherino.StoredMessage{Message: hasherino.ChatMessage{
+		Command: "PRIVMSG",
+		Author:  author,
+		Text:    "message " + strconv.Itoa(id),
+		Tags:    map[string]string{"id": strconv.Itoa(id)},
+	}}
+}
+
+func TestMessageStore(t *testing.T) {
+	store := hasherino.NewMessageStore(3)
+	changes := []hasherino.MessageStoreChange{}
+	store.SetOnChange(func(change hasherino.MessageStoreChange) {
+		changes = append(changes, change)
+	})
+	for i := 0; i < 5; i++ {
+		author := "foo"
+		if i%2 == 1 {
+			author = "bar"
+		}
+		store.Add(storedMessage(i, author))
+	}
+
+	if store.Len() != 3 || store.At(0).Message.Id() != "2" || store.At(2).Message.Id() != "4" || store.At(3) != nil {
+		t.Fatalf("expected messages 2 to 4, got %d messages", store.Len())
+	}
+	if store.Get("1") != nil || store.IndexOf("1") != -1 {
+		t.Error("expected dropped messages to be gone")
+	}
+	if store.IndexOf("3") != 1 || store.Get("3").Message.Author != "bar" {
+		t.Error("expected to find message 3 by id")
+	}
+	if byFoo := store.ByUser("foo"); len(byFoo) != 2 || byFoo[0].Message.Id() != "2" {
+		t.Errorf("unexpected messages by foo %v", byFoo)
+	}
+
+	if !store.MarkDeleted("3") || store.MarkDeleted("1") || !store.Get("3").Deleted {
+		t.Error("expected only stored messages to be marked deleted")
+	}
+	if deleted := store.MarkUserDeleted("foo"); deleted != 2 {
+		t.Errorf("expected 2 messages of foo deleted, got %d", deleted)
+	}
+
+	store.SetCapacity(2)
+	if store.Len() != 2 || store.At(0).Message.Id() != "3" || store.Get("2") != nil {
+		t.Error("expected shrinking to keep the newest messages")
+	}
+	store.Add(storedMessage(5, "foo"))
+	if store.At(0).Message.Id() != "4" || store.IndexOf("5") != 1 {
+		t.Error("expected the store to keep wrapping after shrinking")
+	}
+
+	// History goes before what's stored, skipping what's already there
+	store.SetCapacity(4)
+	store.Prepend([]*hasherino.StoredMessage{storedMessage(0, "foo"), storedMessage(1, "bar"), storedMessage(4, "bar")})
+	if store.Len() != 4 || store.At(0).Message.Id() != "0" || store.At(2).Message.Id() != "4" || store.IndexOf("5") != 3 {
+		t.Errorf("unexpected messages after prepending, first %s", store.At(0).Message.Id())
+	}
+
+	if len(changes) != 9 || changes[0] != hasherino.MessageAdded || changes[5] != hasherino.MessagesDeleted {
+		t.Errorf("unexpected changes %v", changes)
+	}
+}
+
+func TestMessageStoreInsert(t *testing.T) {
+	store := hasherino.NewMessageStore(3)
+	sentAt := func(id int, ts string) *hasherino.StoredMessage {
+		message := storedMessage(id, "foo")
+		message.Message.Tags["tmi-sent-ts"] = ts
+		return message
+	}
+	store.Add(sentAt(0, "1000"))
+	store.Add(sentAt(1, "3000"))
+	store.Insert(sentAt(2, "2000"))
+	store.Insert(sentAt(1, "3000"))
+
+	ids := []string{}
+	for _, message := range store.Messages() {
+		ids = append(ids, message.Message.Id())
+	}
+	if strings.Join(ids, ",") != "0,2,1" {
+		t.Errorf("expected the late message between the others once, got %v", ids)
+	}
+	if store.IndexOf("1") != 2 {
+		t.Error("expected ids to follow the inserted message")
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"gopkg.in/irc.v4"
 	"nhooyr.io/websocket"
 )
 
@@ -19,13 +22,17 @@ const (
 
 type TwitchChatWebsocket struct {
 	State WebsocketState
+	// Called by Listen after it reconnected and rejoined the channels, with how long it was disconnected
+	OnReconnect func(downtime time.Duration)
 
 	url              string
 	context          context.Context
 	cancel           context.CancelFunc
 	connection       *websocket.Conn
 	initial_messages *[]string
+	channelsMutex    sync.Mutex // channels is read by the controller while Listen rejoins them
 	channels         map[string]struct{}
+	closed           atomic.Bool
 }
 
 func (w *TwitchChatWebsocket) New(token string, user string) (*TwitchChatWebsocket, error) {
@@ -72,6 +79,7 @@ func (w *TwitchChatWebsocket) Connect() error {
 
 func (w *TwitchChatWebsocket) Close() {
 	log.Println("closing websocket")
+	w.closed.Store(true)
 	w.cancel()
 	w.connection.Close(websocket.StatusNormalClosure, "")
 	w.State = Disconnected
@@ -85,42 +93,58 @@ func (w *TwitchChatWebsocket) Listen(callback func(message string)) error {
 	for {
 		_, content, err := w.connection.Read(w.context)
 		if err != nil {
+			if w.closed.Load() {
+				return nil
+			}
+			disconnectedAt := time.Now()
 			log.Println("error: '", err, "', attempting reconnect")
 			w.State = Disconnected
-
-			c, ctx, cancel, err := w.dial(w.url)
-			w.connection = c
-			w.context = *ctx
-			w.cancel = *cancel
-			if err != nil {
-				log.Println("failed to redial:", err)
-				time.Sleep(time.Second * 2)
-				continue
-			}
-			err = w.Connect()
-			if err != nil {
-				log.Println("failed to reconnect:", err)
+			for !w.reconnect() {
 				time.Sleep(time.Second * 2)
-				continue
+				if w.closed.Load() {
+					return nil
+				}
 			}
-			keys := make([]string, 0, len(w.channels))
-			for k := range w.channels {
-				keys = append(keys, k)
-			}
-			err = w.Join(keys...)
-			if err != nil {
-				log.Println("failed to rejoin:", err)
-				time.Sleep(time.Second * 2)
-				continue
+			if w.OnReconnect != nil {
+				w.OnReconnect(time.Since(disconnectedAt))
 			}
+			continue
 		}
 		s := string(content)
 		fmt.Println("Message: " + s)
 		callback(s)
 	}
-	w.Close()
+}
 
-	return nil
+// Dials again and rejoins the channels, returns false if any step failed
+func (w *TwitchChatWebsocket) reconnect() bool {
+	c, ctx, cancel, err := w.dial(w.url)
+	if err != nil {
+		log.Println("failed to redial:", err)
+		(*cancel)()
+		return false
+	}
+	w.connection = c
+	w.context = *ctx
+	w.cancel = *cancel
+	err = w.Connect()
+	if err != nil {
+		log.Println("failed to reconnect:", err)
+		c.Close(websocket.StatusInternalError, "")
+		w.State = Disconnected
+		return false
+	}
+	keys := w.Channels()
+	if len(keys) > 0 {
+		err = w.Join(keys...)
+		if err != nil {
+			log.Println("failed to rejoin:", err)
+			c.Close(websocket.StatusInternalError, "")
+			w.State = Disconnected
+			return false
+		}
+	}
+	return true
 }
 
 func (w *TwitchChatWebsocket) Join(channelStrings ...string) error {
@@ -137,18 +161,37 @@ func (w *TwitchChatWebsocket) Join(channelStrings ...string) error {
 	if err != nil {
 		return err
 	}
+	w.channelsMutex.Lock()
 	for _, channel := range channelStrings {
 		w.channels[channel] = struct{}{}
 	}
+	w.channelsMutex.Unlock()
 	return nil
 }
 
+// The joined channels
+func (w *TwitchChatWebsocket) Channels() []string {
+	w.channelsMutex.Lock()
+	defer w.channelsMutex.Unlock()
+	channels := make([]string, 0, len(w.channels))
+	for channel := range w.channels {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+func (w *TwitchChatWebsocket) HasChannel(channel string) bool {
+	w.channelsMutex.Lock()
+	defer w.channelsMutex.Unlock()
+	_, ok := w.channels[channel]
+	return ok
+}
+
 func (w *TwitchChatWebsocket) Part(channel string) error {
 	if w.State != Connected {
 		return errors.New("Not connected")
 	}
-	_, ok := w.channels[channel]
-	if !ok {
+	if !w.HasChannel(channel) {
 		return errors.New("Not in channel")
 	}
 
@@ -157,15 +200,27 @@ func (w *TwitchChatWebsocket) Part(channel string) error {
 		return err
 	}
 
+	w.channelsMutex.Lock()
 	delete(w.channels, channel)
+	w.channelsMutex.Unlock()
 	return nil
 }
 
 func (w *TwitchChatWebsocket) Send(channel string, message string) error {
+	return w.SendTagged(channel, message, nil)
+}
+
+// Sends a PRIVMSG with IRCv3 tags like reply-parent-msg-id and client-nonce
+func (w *TwitchChatWebsocket) SendTagged(channel string, message string, tags map[string]string) error {
 	if w.State != Connected {
 		return errors.New("Not connected")
 	}
-	err := w.connection.Write(w.context, websocket.MessageText, []byte("PRIVMSG #"+channel+" :"+message))
+	msg := &irc.Message{
+		Tags:    tags,
+		Command: "PRIVMSG",
+		Params:  []string{"#" + channel, message},
+	}
+	err := w.connection.Write(w.context, websocket.MessageText, []byte(msg.String()))
 	if err != nil {
 		return err
 	}
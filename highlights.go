@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"log"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+// Shows a form editing rule, calling onSaved after it's saved
+func showHighlightRuleForm(hc *hasherino.HasherinoController, rule *hasherino.HighlightRule, w fyne.Window, onSaved func()) {
+	kindNames := []string{}
+	for _, kind := range hasherino.HighlightKinds {
+		kindNames = append(kindNames, kind.String())
+	}
+	kindSelect := widget.NewSelect(kindNames, nil)
+	kindSelect.SetSelected(rule.Kind.String())
+	pattern := widget.NewEntry()
+	pattern.SetText(rule.Pattern)
+	pattern.SetPlaceHolder("Phrase, regex, login or badge name")
+	caseSensitive := widget.NewCheck("", nil)
+	caseSensitive.SetChecked(rule.CaseSensitive)
+	colorEntry := widget.NewEntry()
+	colorEntry.SetText(rule.Color)
+	colorEntry.SetPlaceHolder("#rrggbb")
+	pickColor := widget.NewButton("Pick", func() {
+		picker := dialog.NewColorPicker("Highlight color", "", func(c color.Color) {
+			r, g, b, _ := c.RGBA()
+			colorEntry.SetText(fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8))
+		}, w)
+		picker.Advanced = true
+		picker.Show()
+	})
+	// Only the text rules have a case to match
+	kindSelect.OnChanged = func(string) {
+		switch hasherino.HighlightKinds[kindSelect.SelectedIndex()] {
+		case hasherino.HighlightMention, hasherino.HighlightPhrase, hasherino.HighlightRegex:
+			caseSensitive.Enable()
+		default:
+			caseSensitive.Disable()
+		}
+	}
+	kindSelect.OnChanged(kindSelect.Selected)
+	sound := widget.NewCheck("", nil)
+	sound.SetChecked(rule.Sound)
+	enabled := widget.NewCheck("", nil)
+	enabled.SetChecked(rule.Enabled)
+
+	dialog.ShowForm("Highlight", "Save", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Type", kindSelect),
+		widget.NewFormItem("Pattern", pattern),
+		widget.NewFormItem("Case sensitive", caseSensitive),
+		widget.NewFormItem("Color", container.NewBorder(nil, nil, nil, pickColor, colorEntry)),
+		widget.NewFormItem("Play sound", sound),
+		widget.NewFormItem("Enabled", enabled),
+	}, func(save bool) {
+		if !save {
+			return
+		}
+		rule.Kind = hasherino.HighlightKinds[kindSelect.SelectedIndex()]
+		rule.Pattern = pattern.Text
+		rule.CaseSensitive = caseSensitive.Checked
+		rule.Color = colorEntry.Text
+		rule.Sound = sound.Checked
+		rule.Enabled = enabled.Checked
+		if err := hc.SaveHighlightRule(rule); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		onSaved()
+	}, w)
+}
+
+// Settings tab listing the highlight rules, checked top to bottom
+func NewHighlightSettings(hc *hasherino.HasherinoController, w fyne.Window) fyne.CanvasObject {
+	var rules []*hasherino.HighlightRule
+	var selected *hasherino.HighlightRule
+
+	list := widget.NewList(
+		func() int {
+			return len(rules)
+		},
+		func() fyne.CanvasObject {
+			swatch := canvas.NewRectangle(color.Transparent)
+			swatch.SetMinSize(fyne.NewSize(20, 20))
+			return container.NewBorder(nil, nil, container.NewCenter(swatch), nil, widget.NewLabel("template"))
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			rule := rules[i]
+			objects := o.(*fyne.Container).Objects
+			text := rule.Kind.String()
+			if rule.Pattern != "" {
+				text += ": " + rule.Pattern
+			}
+			if rule.Sound {
+				text += " 🔔"
+			}
+			if !rule.Enabled {
+				text += " (disabled)"
+			}
+			objects[0].(*widget.Label).SetText(text)
+			swatch := objects[1].(*fyne.Container).Objects[0].(*canvas.Rectangle)
+			swatch.FillColor = highlightColor(rule.Color)
+			swatch.Refresh()
+		})
+	list.OnSelected = func(i widget.ListItemID) {
+		selected = rules[i]
+	}
+	reload := func() {
+		result, err := hc.GetHighlightRules()
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		rules = result
+		selected = nil
+		list.UnselectAll()
+		list.Refresh()
+	}
+	reload()
+
+	buttons := container.NewHBox(
+		widget.NewButton("Add", func() {
+			rule := &hasherino.HighlightRule{Kind: hasherino.HighlightPhrase, Color: "#7f3f49", Enabled: true}
+			showHighlightRuleForm(hc, rule, w, reload)
+		}),
+		widget.NewButton("Edit", func() {
+			if selected == nil {
+				dialog.ShowError(errors.New("No highlight selected"), w)
+				return
+			}
+			rule := *selected
+			showHighlightRuleForm(hc, &rule, w, reload)
+		}),
+		widget.NewButton("Remove", func() {
+			if selected == nil {
+				dialog.ShowError(errors.New("No highlight selected"), w)
+				return
+			}
+			if err := hc.DeleteHighlightRule(selected.Id); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			reload()
+		}),
+	)
+	return container.NewBorder(nil, buttons, nil, nil, list)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"log"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+func showIgnoredPhraseForm(hc *hasherino.HasherinoController, phrase *hasherino.IgnoredPhrase, w fyne.Window, onSaved func()) {
+	pattern := widget.NewEntry()
+	pattern.SetText(phrase.Pattern)
+	regex := widget.NewCheck("", nil)
+	regex.SetChecked(phrase.Regex)
+	caseSensitive := widget.NewCheck("", nil)
+	caseSensitive.SetChecked(phrase.CaseSensitive)
+	replacement := widget.NewEntry()
+	replacement.SetText(phrase.Replacement)
+	replacement.SetPlaceHolder("Hide the message")
+	replace := widget.NewCheck("", func(checked bool) {
+		if checked {
+			replacement.Enable()
+		} else {
+			replacement.Disable()
+		}
+	})
+	replace.SetChecked(phrase.Replace)
+	replace.OnChanged(phrase.Replace)
+
+	dialog.ShowForm("Ignored phrase", "Save", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Pattern", pattern),
+		widget.NewFormItem("Regex", regex),
+		widget.NewFormItem("Case sensitive", caseSensitive),
+		widget.NewFormItem("Replace", replace),
+		widget.NewFormItem("Replace with", replacement),
+	}, func(save bool) {
+		if !save {
+			return
+		}
+		phrase.Pattern = pattern.Text
+		phrase.Regex = regex.Checked
+		phrase.CaseSensitive = caseSensitive.Checked
+		phrase.Replace = replace.Checked
+		phrase.Replacement = replacement.Text
+		if err := hc.SaveIgnoredPhrase(phrase); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		onSaved()
+	}, w)
+}
+
+// Settings tab with the ignored users and phrases
+func NewIgnoreSettings(hc *hasherino.HasherinoController, w fyne.Window) fyne.CanvasObject {
+	// Users
+	var users []*hasherino.IgnoredUser
+	var selectedUser *hasherino.IgnoredUser
+	userList := widget.NewList(
+		func() int {
+			return len(users)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("template")
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			text := users[i].Login
+			if users[i].Synced {
+				text += " (blocked on Twitch)"
+			}
+			o.(*widget.Label).SetText(text)
+		})
+	userList.OnSelected = func(i widget.ListItemID) {
+		selectedUser = users[i]
+	}
+	reloadUsers := func() {
+		result, err := hc.GetIgnoredUsers()
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		users = result
+		selectedUser = nil
+		userList.UnselectAll()
+		userList.Refresh()
+	}
+	reloadUsers()
+
+	userButtons := container.NewHBox(
+		widget.NewButton("Add", func() {
+			login := widget.NewEntry()
+			block := widget.NewCheck("", nil)
+			dialog.ShowForm("Ignore user", "Ignore", "Cancel", []*widget.FormItem{
+				widget.NewFormItem("Login", login),
+				widget.NewFormItem("Block on Twitch", block),
+			}, func(ignore bool) {
+				if !ignore {
+					return
+				}
+				if err := hc.IgnoreUser(login.Text, block.Checked); err != nil {
+					dialog.ShowError(err, w)
+					return
+				}
+				reloadUsers()
+			}, w)
+		}),
+		widget.NewButton("Remove", func() {
+			if selectedUser == nil {
+				dialog.ShowError(errors.New("No user selected"), w)
+				return
+			}
+			if err := hc.UnignoreUser(selectedUser.Login); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			reloadUsers()
+		}),
+		widget.NewButton("Sync Twitch blocks", func() {
+			if err := hc.SyncBlockedUsers(); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			reloadUsers()
+		}),
+	)
+
+	// Phrases
+	var phrases []*hasherino.IgnoredPhrase
+	var selectedPhrase *hasherino.IgnoredPhrase
+	phraseList := widget.NewList(
+		func() int {
+			return len(phrases)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("template")
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			phrase := phrases[i]
+			text := phrase.Pattern
+			if phrase.Regex {
+				text = "/" + text + "/"
+			}
+			if phrase.Replace {
+				text += " → " + phrase.Replacement
+			}
+			o.(*widget.Label).SetText(text)
+		})
+	phraseList.OnSelected = func(i widget.ListItemID) {
+		selectedPhrase = phrases[i]
+	}
+	reloadPhrases := func() {
+		result, err := hc.GetIgnoredPhrases()
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		phrases = result
+		selectedPhrase = nil
+		phraseList.UnselectAll()
+		phraseList.Refresh()
+	}
+	reloadPhrases()
+
+	phraseButtons := container.NewHBox(
+		widget.NewButton("Add", func() {
+			showIgnoredPhraseForm(hc, &hasherino.IgnoredPhrase{}, w, reloadPhrases)
+		}),
+		widget.NewButton("Edit", func() {
+			if selectedPhrase == nil {
+				dialog.ShowError(errors.New("No phrase selected"), w)
+				return
+			}
+			phrase := *selectedPhrase
+			showIgnoredPhraseForm(hc, &phrase, w, reloadPhrases)
+		}),
+		widget.NewButton("Remove", func() {
+			if selectedPhrase == nil {
+				dialog.ShowError(errors.New("No phrase selected"), w)
+				return
+			}
+			if err := hc.DeleteIgnoredPhrase(selectedPhrase.Id); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			reloadPhrases()
+		}),
+	)
+
+	bold := fyne.TextStyle{Bold: true}
+	return container.NewVSplit(
+		container.NewBorder(widget.NewLabelWithStyle("Users", fyne.TextAlignLeading, bold), userButtons, nil, nil, userList),
+		container.NewBorder(widget.NewLabelWithStyle("Phrases", fyne.TextAlignLeading, bold), phraseButtons, nil, nil, phraseList),
+	)
+}
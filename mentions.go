@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+const mentionsTabName = "Mentions"
+
+// Tab collecting the highlighted messages of every channel, newest at the bottom
+func NewMentionsTab(hc *hasherino.HasherinoController) *container.TabItem {
+	// Sized from the settings as messages arrive, so a changed limit applies right away
+	store := hasherino.NewMessageStore(0)
+	// Rows are reused across channels, user cards need the one currently shown
+	rowChannels := make(map[*chatLineRow]string)
+	list := widget.NewList(
+		func() int {
+			return store.Len()
+		},
+		func() fyne.CanvasObject {
+			var row *chatLineRow
+			row = newChatLineRow(func(login string) {
+				channel := rowChannels[row]
+				recent := []hasherino.ChatMessage{}
+				for _, line := range store.ByUser(login) {
+					if line.Message.Channel == channel {
+						recent = append(recent, line.Message)
+					}
+				}
+				ShowUserCard(hc, channel, login, recent)
+			}, nil, nil)
+			return container.NewBorder(nil, nil, widget.NewLabel("template"), nil, row)
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			line := store.At(i)
+			if line == nil {
+				return
+			}
+			objects := o.(*fyne.Container).Objects
+			row := objects[0].(*chatLineRow)
+			row.SetLine(line, nil)
+			rowChannels[row] = line.Message.Channel
+			objects[1].(*widget.Label).SetText("#" + line.Message.Channel)
+		})
+	// Mentions arrive on the chat connection's goroutine
+	store.SetOnChange(func(change hasherino.MessageStoreChange) {
+		fyne.Do(func() {
+			if change == hasherino.MessageAdded {
+				list.ScrollToBottom()
+			}
+			list.Refresh()
+		})
+	})
+
+	hc.SetMentionCallback(func(message hasherino.ChatMessage) {
+		settings, err := hc.GetSettings()
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		store.SetCapacity(settings.ChatMessageLimit)
+		store.Add(&hasherino.StoredMessage{Message: message, Badges: hc.GetMessageBadges(&message)})
+	})
+	return container.NewTabItem(mentionsTabName, list)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+var errClickThrough = errors.New("clicks can't go through windows on this platform")
+
+// Clears every window with a transparent background instead of the theme's, fyne clears them all
+// with the app's theme. Each window draws the background itself with windowBackground, which
+// lets overlays draw less of it.
+type clearTheme struct {
+	fyne.Theme
+}
+
+func (t clearTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
+	if name == theme.ColorNameBackground {
+		return color.Transparent
+	}
+	return t.Theme.Color(name, variant)
+}
+
+// Content of a window, over the theme's background
+func windowContent(content fyne.CanvasObject) fyne.CanvasObject {
+	return container.NewStack(newWindowBackground(0xff), content)
+}
+
+// The theme's background with alpha, following the theme when it changes
+type windowBackground struct {
+	widget.BaseWidget
+	alpha uint8
+}
+
+func newWindowBackground(alpha uint8) *windowBackground {
+	b := &windowBackground{alpha: alpha}
+	b.ExtendBaseWidget(b)
+	return b
+}
+
+func (b *windowBackground) CreateRenderer() fyne.WidgetRenderer {
+	r := &windowBackgroundRenderer{background: b, rectangle: canvas.NewRectangle(color.Transparent)}
+	r.Refresh()
+	return r
+}
+
+type windowBackgroundRenderer struct {
+	background *windowBackground
+	rectangle  *canvas.Rectangle
+}
+
+func (r *windowBackgroundRenderer) Layout(size fyne.Size) {
+	r.rectangle.Resize(size)
+}
+
+func (r *windowBackgroundRenderer) MinSize() fyne.Size {
+	return fyne.Size{}
+}
+
+func (r *windowBackgroundRenderer) Refresh() {
+	th := r.background.Theme()
+	if clear, ok := th.(clearTheme); ok {
+		th = clear.Theme
+	}
+	variant := fyne.CurrentApp().Settings().ThemeVariant()
+	fill := color.NRGBAModel.Convert(th.Color(theme.ColorNameBackground, variant)).(color.NRGBA)
+	fill.A = uint8(uint16(fill.A) * uint16(r.background.alpha) / 0xff)
+	r.rectangle.FillColor = fill
+	r.rectangle.Refresh()
+}
+
+func (r *windowBackgroundRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.rectangle}
+}
+
+func (r *windowBackgroundRenderer) Destroy() {}
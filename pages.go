@@ -0,0 +1,410 @@
+package main
+
+import (
+	"log"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+// Asks for a channel to join, keeping the dialog open until add succeeds
+func showAddChannelDialog(title string, w fyne.Window, add func(channel string) error) {
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder("Channel")
+	items := []*widget.FormItem{
+		widget.NewFormItem("Channel", entry),
+	}
+	var addDialog *dialog.FormDialog
+	submit := func(b bool) {
+		if !b {
+			return
+		}
+		if err := add(entry.Text); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		addDialog.Hide()
+	}
+	addDialog = dialog.NewForm(title, "Add", "Cancel", items, submit, w)
+	entry.OnSubmitted = func(string) {
+		submit(true)
+	}
+	addDialog.Show()
+	w.Canvas().Focus(entry)
+}
+
+// Leaves channel and forgets the callbacks of its pane
+func closeChannel(hc *hasherino.HasherinoController, channel string) error {
+	tab, err := hc.GetTab(channel)
+	if err != nil {
+		return err
+	}
+	if err := hc.RemoveTab(tab.Id); err != nil {
+		return err
+	}
+	hc.SetSettingsCallback(channel, nil)
+	hc.SetChatCallback(channel, nil)
+	delete(jumpMap, channel)
+	delete(findMap, channel)
+	return nil
+}
+
+// Bar above a split with its channel's name, dragged onto another split of the page to move it
+// next to it
+type paneHandle struct {
+	widget.BaseWidget
+	label     *widget.Label
+	onDragged func(position fyne.Position)
+	onDragEnd func()
+}
+
+func newPaneHandle(channel string, onDragged func(position fyne.Position), onDragEnd func()) *paneHandle {
+	h := &paneHandle{
+		label:     widget.NewLabelWithStyle(channel, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		onDragged: onDragged,
+		onDragEnd: onDragEnd,
+	}
+	h.ExtendBaseWidget(h)
+	return h
+}
+
+func (h *paneHandle) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(h.label)
+}
+
+func (h *paneHandle) Dragged(event *fyne.DragEvent) {
+	h.onDragged(event.AbsolutePosition)
+}
+
+func (h *paneHandle) DragEnd() {
+	h.onDragEnd()
+}
+
+// Tab of the main window showing the channels of a page in splits
+type chatPage struct {
+	page   *hasherino.Page
+	item   *container.TabItem
+	tabs   *container.AppTabs
+	hc     *hasherino.HasherinoController
+	window fyne.Window
+
+	panes   map[string]fyne.CanvasObject // by channel, kept across layout changes
+	bodies  map[string]*fyne.Container   // what a pane shows below its header
+	chats   map[string]*chatPane
+	windows map[string]fyne.Window       // of the popped out channels
+	popOuts map[string]*hasherino.PopOut // how they were popped out
+	closing bool                         // the main window is closing, popped out windows stay open next time
+	splits  map[*hasherino.LayoutNode]*container.Split
+	dragTo  fyne.Position
+	onEmpty func() // called once the last split was closed and the page deleted
+}
+
+func newChatPage(
+	hc *hasherino.HasherinoController,
+	window fyne.Window,
+	tabs *container.AppTabs,
+	page *hasherino.Page,
+	onEmpty func(),
+) *chatPage {
+	p := &chatPage{
+		page:    page,
+		item:    container.NewTabItem(page.Name(), widget.NewLabel("")),
+		tabs:    tabs,
+		hc:      hc,
+		window:  window,
+		panes:   make(map[string]fyne.CanvasObject),
+		bodies:  make(map[string]*fyne.Container),
+		chats:   make(map[string]*chatPane),
+		windows: make(map[string]fyne.Window),
+		popOuts: make(map[string]*hasherino.PopOut),
+		onEmpty: onEmpty,
+	}
+	p.render()
+	return p
+}
+
+func (p *chatPage) pane(channel string) fyne.CanvasObject {
+	if pane, ok := p.panes[channel]; ok {
+		return pane
+	}
+	handle := newPaneHandle(channel, func(position fyne.Position) {
+		p.dragTo = position
+	}, func() {
+		p.drop(channel)
+	})
+	splitRight := widget.NewButton("Split →", func() {
+		p.split(channel, hasherino.SplitHorizontal)
+	})
+	splitDown := widget.NewButton("Split ↓", func() {
+		p.split(channel, hasherino.SplitVertical)
+	})
+	popOut := widget.NewButton("Pop out", func() {
+		p.popOut(channel, false)
+	})
+	overlay := widget.NewButton("Overlay", func() {
+		p.popOut(channel, true)
+	})
+	closeButton := widget.NewButtonWithIcon("", theme.CancelIcon(), func() {
+		p.closeSplit(channel)
+	})
+	for _, button := range []*widget.Button{splitRight, splitDown, popOut, overlay, closeButton} {
+		button.Importance = widget.LowImportance
+	}
+	buttons := container.NewHBox(splitRight, splitDown, popOut, overlay, closeButton)
+	header := container.NewBorder(nil, nil, nil, buttons, handle)
+	chat := NewChatPane(channel, p.hc, p.window)
+	body := container.NewStack(chat.content)
+	pane := container.NewBorder(header, nil, nil, nil, body)
+	p.panes[channel] = pane
+	p.bodies[channel] = body
+	p.chats[channel] = chat
+	return pane
+}
+
+func (p *chatPage) build(node *hasherino.LayoutNode) fyne.CanvasObject {
+	if node.IsLeaf() {
+		return p.pane(node.Channel)
+	}
+	first, second := p.build(node.Children[0]), p.build(node.Children[1])
+	var split *container.Split
+	if node.Split == hasherino.SplitHorizontal {
+		split = container.NewHSplit(first, second)
+	} else {
+		split = container.NewVSplit(first, second)
+	}
+	split.Offset = node.Offset
+	p.splits[node] = split
+	return split
+}
+
+func (p *chatPage) render() {
+	p.splits = make(map[*hasherino.LayoutNode]*container.Split)
+	p.item.Content = p.build(p.page.Root)
+	p.item.Text = p.page.Name()
+	p.tabs.Refresh()
+}
+
+// Copies the split offsets as they were dragged to the layout, before it changes
+func (p *chatPage) syncOffsets() {
+	for node, split := range p.splits {
+		node.Offset = split.Offset
+	}
+}
+
+func (p *chatPage) save() {
+	p.syncOffsets()
+	if err := p.hc.SavePage(p.page); err != nil {
+		dialog.ShowError(err, p.window)
+	}
+}
+
+func (p *chatPage) split(channel string, direction hasherino.SplitDirection) {
+	showAddChannelDialog("Split "+channel, p.window, func(newChannel string) error {
+		newChannel, err := p.hc.AddTab(newChannel)
+		if err != nil {
+			return err
+		}
+		p.syncOffsets()
+		p.page.Root.SplitLeaf(channel, newChannel, direction, false)
+		p.save()
+		p.render()
+		return nil
+	})
+}
+
+func (p *chatPage) closeSplit(channel string) {
+	p.closePopOut(channel, false)
+	if err := closeChannel(p.hc, channel); err != nil {
+		dialog.ShowError(err, p.window)
+		return
+	}
+	delete(p.panes, channel)
+	delete(p.bodies, channel)
+	delete(p.chats, channel)
+	p.syncOffsets()
+	p.page.Root = p.page.Root.Remove(channel)
+	if p.page.Root == nil {
+		if err := p.hc.DeletePage(p.page.Id); err != nil {
+			dialog.ShowError(err, p.window)
+		}
+		p.onEmpty()
+		return
+	}
+	p.save()
+	p.render()
+}
+
+// Moves the dragged split of channel next to the split it was dropped on, on the side of the
+// edge it was dropped closest to
+func (p *chatPage) drop(channel string) {
+	driver := fyne.CurrentApp().Driver()
+	for target, pane := range p.panes {
+		if target == channel {
+			continue
+		}
+		position, size := driver.AbsolutePositionForObject(pane), pane.Size()
+		x, y := (p.dragTo.X-position.X)/size.Width, (p.dragTo.Y-position.Y)/size.Height
+		if x < 0 || x > 1 || y < 0 || y > 1 {
+			continue
+		}
+		direction, before := hasherino.SplitHorizontal, x < 0.5
+		if min(y, 1-y) < min(x, 1-x) {
+			direction, before = hasherino.SplitVertical, y < 0.5
+		}
+		p.syncOffsets()
+		p.page.Root = p.page.Root.Move(channel, target, direction, before)
+		p.save()
+		p.render()
+		return
+	}
+}
+
+// Shows channel in a window of its own, placed and sized as it was last time. Overlays only show
+// the messages on a transparent background, above the other windows. Its split shows a button to
+// bring it back until the window closes, and whether clicks go through an overlay.
+func (p *chatPage) popOut(channel string, overlay bool) {
+	if window, ok := p.windows[channel]; ok {
+		window.RequestFocus()
+		return
+	}
+	chat, body := p.chats[channel], p.bodies[channel]
+	saved, err := p.hc.GetPopOut(channel)
+	if err != nil {
+		dialog.ShowError(err, p.window)
+		return
+	}
+	saved.Overlay = overlay
+	size := fyne.NewSize(400, 600)
+	if saved.Width > 0 && saved.Height > 0 {
+		size = fyne.NewSize(saved.Width, saved.Height)
+	}
+
+	title := channel
+	if overlay {
+		title += " overlay"
+	}
+	window := fyne.CurrentApp().NewWindow(title)
+	window.SetPadded(!overlay)
+	chat.SetReducedChrome(overlay)
+	chat.SetWindow(window)
+	content := windowContent(chat.content)
+	if overlay {
+		// Keeps the messages legible over whatever is behind the window
+		content = container.NewStack(newWindowBackground(0x99), chat.content)
+	}
+
+	bringBack := widget.NewButton("Bring back "+channel, func() {
+		p.closePopOut(channel, false)
+	})
+	placeholder := container.NewVBox(bringBack)
+	if overlay {
+		// Once clicks go through, the overlay can't be clicked to stop it, so it's toggled from here
+		clickThrough := widget.NewCheck("Click-through", nil)
+		clickThrough.Checked = saved.ClickThrough
+		clickThrough.OnChanged = func(enabled bool) {
+			if err := setClickThrough(window, enabled); err != nil {
+				dialog.ShowError(err, p.window)
+				return
+			}
+			saved.ClickThrough = enabled
+			if err := p.hc.SavePopOut(saved); err != nil {
+				dialog.ShowError(err, p.window)
+			}
+		}
+		if !clickThroughSupported {
+			clickThrough.Disable()
+		}
+		placeholder.Add(clickThrough)
+	}
+	body.Objects = []fyne.CanvasObject{container.NewCenter(placeholder)}
+	body.Refresh()
+
+	window.SetCloseIntercept(func() {
+		p.closePopOut(channel, false)
+	})
+	window.SetOnClosed(func() {
+		if p.closing {
+			return
+		}
+		chat.SetReducedChrome(false)
+		chat.SetWindow(p.window)
+		body.Objects = []fyne.CanvasObject{chat.content}
+		body.Refresh()
+	})
+	window.SetContent(content)
+	window.Resize(size)
+	showWindow(window, overlay)
+	if saved.Placed {
+		moveWindow(window, saved.X, saved.Y)
+	}
+	if overlay {
+		keepOnTop(window)
+		if saved.ClickThrough {
+			if err := setClickThrough(window, true); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+	p.windows[channel] = window
+	p.popOuts[channel] = saved
+
+	saved.Open = true
+	if err := p.hc.SavePopOut(saved); err != nil {
+		dialog.ShowError(err, p.window)
+	}
+}
+
+// Remembers where the window of channel was left and closes it, open says whether to reopen it
+// on the next start
+func (p *chatPage) closePopOut(channel string, open bool) {
+	window, ok := p.windows[channel]
+	if !ok {
+		return
+	}
+	saved := p.popOuts[channel]
+	size := window.Canvas().Size()
+	saved.Width, saved.Height = size.Width, size.Height
+	// Asked before closing since the window is gone by the time it's closed
+	if x, y, ok := windowPosition(window); ok {
+		saved.X, saved.Y, saved.Placed = x, y, true
+	}
+	saved.Open = open
+	if err := p.hc.SavePopOut(saved); err != nil {
+		log.Println(err)
+	}
+	delete(p.windows, channel)
+	delete(p.popOuts, channel)
+	window.Close()
+}
+
+// Closes the popped out windows, to reopen them as they were on the next start
+func (p *chatPage) savePopOuts() {
+	p.closing = true
+	for channel := range p.windows {
+		p.closePopOut(channel, true)
+	}
+}
+
+// Whether the page shows channel
+func (p *chatPage) Has(channel string) bool {
+	_, ok := p.panes[channel]
+	return ok
+}
+
+// Leaves every channel of the page and deletes it
+func (p *chatPage) Close() error {
+	for channel := range p.windows {
+		p.closePopOut(channel, false)
+	}
+	for _, channel := range p.page.Root.Channels() {
+		if err := closeChannel(p.hc, channel); err != nil {
+			return err
+		}
+	}
+	return p.hc.DeletePage(p.page.Id)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+type votingOption struct {
+	title  string
+	votes  int
+	detail string
+	winner bool
+}
+
+// Live poll or prediction of a tab, hidden until one begins and after the final result is dismissed
+type votingPanel struct {
+	box *fyne.Container
+	id  string
+}
+
+func newVotingPanel() *votingPanel {
+	p := &votingPanel{box: container.NewVBox()}
+	p.box.Hide()
+	return p
+}
+
+func (p *votingPanel) dismiss() {
+	p.box.RemoveAll()
+	p.box.Hide()
+}
+
+// Replaces the panel's content, bars are relative to the total votes
+func (p *votingPanel) show(id string, title string, status string, options []votingOption, ended bool) {
+	p.id = id
+	total := 0
+	for _, option := range options {
+		total += option.votes
+	}
+
+	p.box.RemoveAll()
+	right := container.NewHBox(widget.NewLabel(status))
+	if ended {
+		right.Add(widget.NewButton("✕", p.dismiss))
+	}
+	p.box.Add(container.NewBorder(nil, nil, nil, right,
+		widget.NewLabelWithStyle(title, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})))
+	for _, option := range options {
+		bar := widget.NewProgressBar()
+		if total > 0 {
+			bar.SetValue(float64(option.votes) / float64(total))
+		}
+		bar.TextFormatter = func() string {
+			return option.detail
+		}
+		label := option.title
+		if option.winner {
+			label = "🏆 " + label
+		}
+		p.box.Add(container.NewGridWithColumns(2, widget.NewLabel(label), bar))
+	}
+	p.box.Show()
+}
+
+func (p *votingPanel) SetPoll(poll *hasherino.PollEvent) {
+	if poll.Status == "archived" {
+		if p.id == poll.Id {
+			p.dismiss()
+		}
+		return
+	}
+
+	options := []votingOption{}
+	most := 0
+	for _, choice := range poll.Choices {
+		most = max(most, choice.Votes)
+	}
+	for _, choice := range poll.Choices {
+		options = append(options, votingOption{
+			title:  choice.Title,
+			votes:  choice.Votes,
+			detail: strconv.Itoa(choice.Votes) + " votes",
+			winner: poll.Ended() && most > 0 && choice.Votes == most,
+		})
+	}
+	status := "Poll"
+	switch poll.Status {
+	case "completed":
+		status = "Poll ended"
+	case "terminated":
+		status = "Poll ended early"
+	}
+	p.show(poll.Id, poll.Title, status, options, poll.Ended())
+}
+
+func (p *votingPanel) SetPrediction(prediction *hasherino.PredictionEvent) {
+	options := []votingOption{}
+	for _, outcome := range prediction.Outcomes {
+		options = append(options, votingOption{
+			title:  outcome.Title,
+			votes:  outcome.ChannelPoints,
+			detail: strconv.Itoa(outcome.ChannelPoints) + " points, " + strconv.Itoa(outcome.Users) + " users",
+			winner: outcome.Id == prediction.WinningOutcomeId,
+		})
+	}
+	status := "Prediction"
+	switch {
+	case prediction.Status == "canceled":
+		status = "Prediction canceled"
+	case prediction.Ended():
+		status = "Prediction ended"
+	case prediction.Locked():
+		status = "Prediction locked"
+	}
+	p.show(prediction.Id, prediction.Title, status, options, prediction.Ended())
+}
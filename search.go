@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+const (
+	searchResultLimit   = 200
+	searchContextLength = 20
+)
+
+func formatIndexedMessage(message *hasherino.IndexedMessage) string {
+	return message.SentAt.Local().Format("2006-01-02 15:04") + " #" + message.Channel + " " +
+		message.DisplayName + ": " + message.Text
+}
+
+func newIndexedMessageList(messages *[]*hasherino.IndexedMessage) *widget.List {
+	return widget.NewList(
+		func() int {
+			return len(*messages)
+		},
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("template")
+			label.Wrapping = fyne.TextWrapWord
+			return label
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(formatIndexedMessage((*messages)[i]))
+		})
+}
+
+// Shows the messages sent around message, for the ones no longer in their tab
+func showMessageContext(hc *hasherino.HasherinoController, message *hasherino.IndexedMessage, w fyne.Window) {
+	messages, err := hc.GetMessageContext(message, searchContextLength)
+	if err != nil {
+		dialog.ShowError(err, w)
+		return
+	}
+	newWindow := fyne.CurrentApp().NewWindow("Context - " + message.Channel)
+	newWindow.Resize(fyne.NewSize(500, 500))
+	list := newIndexedMessageList(&messages)
+	newWindow.SetContent(windowContent(list))
+	newWindow.Show()
+	for i, context := range messages {
+		if context.Id == message.Id {
+			list.Select(i)
+			list.ScrollTo(i)
+		}
+	}
+}
+
+// Window searching the stored messages of every channel. jump scrolls the message's tab to it,
+// returning false if the tab doesn't have it anymore.
+func ShowSearchWindow(hc *hasherino.HasherinoController, jump func(message *hasherino.IndexedMessage) bool) {
+	newWindow := fyne.CurrentApp().NewWindow("Search")
+	newWindow.Resize(fyne.NewSize(500, 600))
+
+	var results []*hasherino.IndexedMessage
+	status := widget.NewLabel("")
+	list := newIndexedMessageList(&results)
+	list.OnSelected = func(i widget.ListItemID) {
+		list.Unselect(i)
+		if !jump(results[i]) {
+			showMessageContext(hc, results[i], newWindow)
+		}
+	}
+
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder("from:user in:channel has:link is:sub after:2024-01-01 before:2024-02-01 text")
+	entry.OnSubmitted = func(query string) {
+		found, err := hc.SearchMessages(query, searchResultLimit)
+		if err != nil {
+			dialog.ShowError(err, newWindow)
+			return
+		}
+		results = found
+		switch len(results) {
+		case 0:
+			status.SetText("No messages found")
+		case searchResultLimit:
+			status.SetText("Showing the newest " + strconv.Itoa(searchResultLimit) + " messages")
+		default:
+			status.SetText(strconv.Itoa(len(results)) + " messages")
+		}
+		list.Refresh()
+		list.ScrollToTop()
+	}
+
+	newWindow.SetContent(windowContent(container.NewBorder(entry, status, nil, nil, list)))
+	newWindow.Show()
+	newWindow.Canvas().Focus(entry)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+// Dialog editing which of channel's messages its tab shows and whether they're logged
+func showTabSettings(hc *hasherino.HasherinoController, channel string, w fyne.Window) {
+	tab, err := hc.GetTab(channel)
+	if err != nil {
+		dialog.ShowError(err, w)
+		return
+	}
+	onlySubscribers := widget.NewCheck("Only subscribers", nil)
+	onlySubscribers.SetChecked(tab.OnlySubscribers)
+	onlyLinks := widget.NewCheck("Only messages with links", nil)
+	onlyLinks.SetChecked(tab.OnlyLinks)
+	hideCommands := widget.NewCheck("Hide bot commands (starting with !)", nil)
+	hideCommands.SetChecked(tab.HideCommands)
+	logging := widget.NewCheck("Log messages to disk", nil)
+	logging.SetChecked(tab.Logging)
+
+	dialog.ShowCustomConfirm("Settings for "+channel, "Save", "Cancel",
+		container.NewVBox(onlySubscribers, onlyLinks, hideCommands, widget.NewSeparator(), logging),
+		func(save bool) {
+			if !save {
+				return
+			}
+			err := hc.SetTabFilters(channel, onlySubscribers.Checked, onlyLinks.Checked, hideCommands.Checked)
+			if err == nil {
+				err = hc.SetTabLogging(channel, logging.Checked)
+			}
+			if err != nil {
+				dialog.ShowError(err, w)
+			}
+		}, w)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Hashy-Software/hasherino-go/components"
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+var userCardTimeouts = []struct {
+	label    string
+	duration time.Duration
+}{
+	{"1m", time.Minute},
+	{"10m", 10 * time.Minute},
+	{"1h", time.Hour},
+	{"1d", 24 * time.Hour},
+}
+
+// Rough age like "3 years" or "5 days"
+func formatAge(since time.Time) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return strconv.Itoa(n) + " " + unit + "s"
+	}
+	days := int(time.Since(since).Hours() / 24)
+	switch {
+	case days >= 365:
+		return plural(days/365, "year")
+	case days >= 30:
+		return plural(days/30, "month")
+	default:
+		return plural(days, "day")
+	}
+}
+
+// Shows login's profile and moderation actions. recent are the messages they sent in channel.
+func ShowUserCard(hc *hasherino.HasherinoController, channel string, login string, recent []hasherino.ChatMessage) {
+	newWindow := fyne.CurrentApp().NewWindow(login + " - " + channel)
+	newWindow.Resize(fyne.NewSize(400, 500))
+	newWindow.SetContent(windowContent(container.NewCenter(widget.NewLabel("Loading..."))))
+	newWindow.Show()
+
+	go func() {
+		card, err := hc.GetUserCard(channel, login)
+		fyne.Do(func() {
+			if err != nil {
+				newWindow.SetContent(windowContent(container.NewCenter(widget.NewLabel("Failed to load user: " + err.Error()))))
+				return
+			}
+			newWindow.SetContent(windowContent(userCardContent(hc, channel, card, recent, newWindow)))
+		})
+	}()
+}
+
+// Profile of card's user with the actions on them, in newWindow
+func userCardContent(
+	hc *hasherino.HasherinoController,
+	channel string,
+	card *hasherino.UserCard,
+	recent []hasherino.ChatMessage,
+	newWindow fyne.Window,
+) fyne.CanvasObject {
+	user := card.User
+
+	avatar := components.NewImageWidget(card.Avatar, fyne.NewSize(96, 96))
+	avatar.LazyLoad()
+	newWindow.SetOnClosed(func() {
+		avatar.LazyUnload()
+	})
+
+	created := "Created " + user.CreatedAt.Format("2 Jan 2006") + " (" + formatAge(user.CreatedAt) + ")"
+	follow := "Follow age unavailable"
+	if card.FollowKnown && card.FollowedAt == nil {
+		follow = "Not following"
+	} else if card.FollowKnown {
+		follow = "Following since " + card.FollowedAt.Format("2 Jan 2006") + " (" + formatAge(*card.FollowedAt) + ")"
+	}
+	badgeNames := []string{}
+	for _, badge := range hasherino.ParseBadges(card.Badges) {
+		badgeNames = append(badgeNames, badge.Name)
+	}
+	info := container.NewVBox(
+		widget.NewLabelWithStyle(user.DisplayName, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel(created),
+		widget.NewLabel(follow),
+	)
+	if len(badgeNames) > 0 {
+		info.Add(widget.NewLabel("Badges: " + strings.Join(badgeNames, ", ")))
+	}
+
+	showResult := func(err error, done string) {
+		if err != nil {
+			dialog.ShowError(err, newWindow)
+			return
+		}
+		dialog.ShowInformation(done, done+" "+user.Login, newWindow)
+	}
+
+	actions := container.NewHBox(
+		widget.NewButton("Copy login", func() {
+			newWindow.Clipboard().SetContent(user.Login)
+		}),
+		widget.NewButton("Open profile", func() {
+			profile, _ := url.Parse("https://www.twitch.tv/" + user.Login)
+			fyne.CurrentApp().OpenURL(profile)
+		}),
+		widget.NewButton("Whisper", func() {
+			entry := widget.NewEntry()
+			dialog.ShowForm("Whisper "+user.Login, "Send", "Cancel",
+				[]*widget.FormItem{widget.NewFormItem("Message", entry)},
+				func(send bool) {
+					if send && entry.Text != "" {
+						if err := hc.SendWhisper(user.Login, entry.Text); err != nil {
+							dialog.ShowError(err, newWindow)
+						}
+					}
+				}, newWindow)
+		}),
+		widget.NewButton("Block", func() {
+			dialog.ShowConfirm("Block", "Block "+user.Login+"?", func(confirmed bool) {
+				if confirmed {
+					showResult(hc.BlockUser(user.ID, user.Login), "Blocked")
+				}
+			}, newWindow)
+		}),
+	)
+
+	moderation := container.NewHBox(widget.NewLabel("Timeout"))
+	for _, timeout := range userCardTimeouts {
+		duration := timeout.duration
+		moderation.Add(widget.NewButton(timeout.label, func() {
+			showResult(hc.BanUser(channel, user.ID, duration, ""), "Timed out")
+		}))
+	}
+	moderation.Add(widget.NewButton("Ban", func() {
+		dialog.ShowConfirm("Ban", "Ban "+user.Login+" from "+channel+"?", func(confirmed bool) {
+			if confirmed {
+				showResult(hc.BanUser(channel, user.ID, 0, ""), "Banned")
+			}
+		}, newWindow)
+	}))
+	moderation.Add(widget.NewButton("Unban", func() {
+		showResult(hc.UnbanUser(channel, user.ID), "Unbanned")
+	}))
+
+	messages := widget.NewList(
+		func() int {
+			return len(recent)
+		},
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("template")
+			label.Wrapping = fyne.TextWrapWord
+			return label
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			message := recent[i]
+			text := message.Text
+			if sent := message.Time(); !sent.IsZero() {
+				text = sent.Format("15:04") + " " + text
+			}
+			o.(*widget.Label).SetText(text)
+		})
+	messages.ScrollToBottom()
+
+	top := container.NewVBox(
+		container.NewBorder(nil, nil, avatar, nil, info),
+		actions,
+		moderation,
+		widget.NewLabel("Recent messages"),
+	)
+	return container.NewBorder(top, nil, nil, nil, messages)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"log"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/Hashy-Software/hasherino-go/hasherino"
+)
+
+const whispersTabName = "Whispers"
+
+// Tab with the whisper conversations of the active account, one per user
+func NewWhispersTab(hc *hasherino.HasherinoController, window fyne.Window) *container.TabItem {
+	var conversations []*hasherino.WhisperConversation
+	var selected *hasherino.WhisperConversation
+	var whispers []*hasherino.Whisper
+
+	title := widget.NewLabelWithStyle("Select a conversation", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	messageList := widget.NewList(
+		func() int {
+			return len(whispers)
+		},
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("template")
+			label.Wrapping = fyne.TextWrapWord
+			return label
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			whisper := whispers[i]
+			author := "You"
+			if whisper.Incoming {
+				author = whisper.DisplayName
+			}
+			o.(*widget.Label).SetText(whisper.CreatedAt.Format("15:04") + " " + author + ": " + whisper.Text)
+		})
+	loadWhispers := func() {
+		if selected == nil {
+			return
+		}
+		result, err := hc.GetWhispers(selected.UserId)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		whispers = result
+		messageList.Refresh()
+		messageList.ScrollToBottom()
+	}
+
+	conversationList := widget.NewList(
+		func() int {
+			return len(conversations)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("template")
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(conversations[i].DisplayName)
+		})
+	conversationList.OnSelected = func(i widget.ListItemID) {
+		selected = conversations[i]
+		title.SetText(selected.DisplayName)
+		loadWhispers()
+	}
+	loadConversations := func() {
+		result, err := hc.GetWhisperConversations()
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		conversations = result
+		conversationList.Refresh()
+	}
+
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder("Whisper")
+	entry.OnSubmitted = func(text string) {
+		if selected == nil || text == "" {
+			return
+		}
+		if err := hc.SendWhisper(selected.Login, text); err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		entry.SetText("")
+	}
+
+	newConversation := widget.NewButton("New whisper", func() {
+		login := widget.NewEntry()
+		message := widget.NewEntry()
+		dialog.ShowForm("New whisper", "Send", "Cancel", []*widget.FormItem{
+			widget.NewFormItem("To", login),
+			widget.NewFormItem("Message", message),
+		}, func(send bool) {
+			if !send || login.Text == "" || message.Text == "" {
+				return
+			}
+			if err := hc.SendWhisper(login.Text, message.Text); err != nil {
+				dialog.ShowError(err, window)
+			}
+		}, window)
+	})
+
+	// Called from the eventsub goroutine for received whispers
+	hc.SetWhisperCallback(func(whisper *hasherino.Whisper) {
+		fyne.Do(func() {
+			loadConversations()
+			if selected != nil && selected.UserId == whisper.UserId {
+				loadWhispers()
+			}
+		})
+	})
+	loadConversations()
+
+	split := container.NewHSplit(
+		container.NewBorder(newConversation, nil, nil, nil, conversationList),
+		container.NewBorder(title, entry, nil, nil, messageList),
+	)
+	split.Offset = 0.3
+	return container.NewTabItem(whispersTabName, split)
+}
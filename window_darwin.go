@@ -0,0 +1,62 @@
+package main
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework Cocoa
+#import <Cocoa/Cocoa.h>
+#include <stdint.h>
+
+static void keepOnTop(uintptr_t window) {
+	[(NSWindow *)window setLevel:NSFloatingWindowLevel];
+}
+
+// Cocoa places windows from the bottom left of the primary screen, positions here are of the
+// content's top left like GLFW's
+static CGFloat flipY(CGFloat y, CGFloat height) {
+	return CGDisplayBounds(CGMainDisplayID()).size.height - y - height;
+}
+
+static void moveWindow(uintptr_t window, int x, int y) {
+	NSWindow *w = (NSWindow *)window;
+	NSRect content = [w contentRectForFrameRect:[w frame]];
+	content.origin.x = x;
+	content.origin.y = flipY(y, content.size.height);
+	[w setFrameOrigin:[w frameRectForContentRect:content].origin];
+}
+
+static void windowPosition(uintptr_t window, int *x, int *y) {
+	NSWindow *w = (NSWindow *)window;
+	NSRect content = [w contentRectForFrameRect:[w frame]];
+	*x = content.origin.x;
+	*y = flipY(content.origin.y, content.size.height);
+}
+
+static void setClickThrough(uintptr_t window, int enabled) {
+	[(NSWindow *)window setIgnoresMouseEvents:enabled ? YES : NO];
+}
+*/
+import "C"
+
+const clickThroughSupported = true
+
+func keepNativeOnTop(handle uintptr) {
+	C.keepOnTop(C.uintptr_t(handle))
+}
+
+func moveNativeWindow(handle uintptr, x, y int) {
+	C.moveWindow(C.uintptr_t(handle), C.int(x), C.int(y))
+}
+
+func nativeWindowPosition(handle uintptr) (x, y int) {
+	var cx, cy C.int
+	C.windowPosition(C.uintptr_t(handle), &cx, &cy)
+	return int(cx), int(cy)
+}
+
+func setNativeClickThrough(handle uintptr, enabled bool) {
+	flag := 0
+	if enabled {
+		flag = 1
+	}
+	C.setClickThrough(C.uintptr_t(handle), C.int(flag))
+}
@@ -0,0 +1,78 @@
+//go:build !js && !wasm
+
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver"
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// Fyne has no API to place windows, keep them on top or let clicks through, so those go to the
+// native window behind a fyne window. Like fyne's own calls, these must be made on the main
+// goroutine, and only do something once the window is shown.
+
+// Shows window, with a framebuffer that can be transparent for overlays. GLFW reads the hint
+// when fyne creates the window on its first Show, so it's reset right after for the others.
+func showWindow(window fyne.Window, transparent bool) {
+	if transparent {
+		glfw.WindowHint(glfw.TransparentFramebuffer, glfw.True)
+		defer glfw.WindowHint(glfw.TransparentFramebuffer, glfw.False)
+	}
+	window.Show()
+}
+
+// Handle of the native window behind window, 0 before it's shown or if the platform isn't
+// supported
+func nativeWindow(window fyne.Window) uintptr {
+	native, ok := window.(driver.NativeWindow)
+	if !ok {
+		return 0
+	}
+	var handle uintptr
+	native.RunNative(func(context any) {
+		switch context := context.(type) {
+		case driver.X11WindowContext:
+			handle = context.WindowHandle
+		case driver.WindowsWindowContext:
+			handle = context.HWND
+		case driver.MacWindowContext:
+			handle = context.NSWindow
+		}
+	})
+	return handle
+}
+
+// Keeps window above the others
+func keepOnTop(window fyne.Window) {
+	if handle := nativeWindow(window); handle != 0 {
+		keepNativeOnTop(handle)
+	}
+}
+
+// Moves window to x, y in screen coordinates
+func moveWindow(window fyne.Window, x, y int) {
+	if handle := nativeWindow(window); handle != 0 {
+		moveNativeWindow(handle, x, y)
+	}
+}
+
+// Where window is in screen coordinates, false if it isn't shown
+func windowPosition(window fyne.Window) (x, y int, ok bool) {
+	handle := nativeWindow(window)
+	if handle == 0 {
+		return 0, 0, false
+	}
+	x, y = nativeWindowPosition(handle)
+	return x, y, true
+}
+
+// Lets clicks on window through to what's behind it, or stops. Errors if the platform can't.
+func setClickThrough(window fyne.Window, enabled bool) error {
+	handle := nativeWindow(window)
+	if !clickThroughSupported || handle == 0 {
+		return errClickThrough
+	}
+	setNativeClickThrough(handle, enabled)
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build (linux || freebsd || netbsd || openbsd) && wayland
+
+package main
+
+// Wayland leaves placement, stacking and input regions to the compositor, fyne gives no native
+// window to change them on
+
+const clickThroughSupported = false
+
+func keepNativeOnTop(handle uintptr) {}
+
+func moveNativeWindow(handle uintptr, x, y int) {}
+
+func nativeWindowPosition(handle uintptr) (x, y int) {
+	return 0, 0
+}
+
+func setNativeClickThrough(handle uintptr, enabled bool) {}
@@ -0,0 +1,25 @@
+//go:build js || wasm
+
+package main
+
+import "fyne.io/fyne/v2"
+
+// Browsers own the placement of the canvas, so pop-outs are plain windows there
+
+const clickThroughSupported = false
+
+func showWindow(window fyne.Window, transparent bool) {
+	window.Show()
+}
+
+func keepOnTop(window fyne.Window) {}
+
+func moveWindow(window fyne.Window, x, y int) {}
+
+func windowPosition(window fyne.Window) (x, y int, ok bool) {
+	return 0, 0, false
+}
+
+func setClickThrough(window fyne.Window, enabled bool) error {
+	return errClickThrough
+}
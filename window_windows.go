@@ -0,0 +1,84 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const (
+	wsExLayered           = 0x80000
+	wsExTransparent       = 0x20
+	lwaAlpha              = 0x2
+	swpNoSize             = 0x1
+	swpNoMove             = 0x2
+	swpNoZOrder           = 0x4
+	swpNoActivate         = 0x10
+	clickThroughSupported = true
+)
+
+var (
+	// Not constants since those can't be negative as a uintptr
+	gwlExStyle  = -20
+	hwndTopMost = -1
+
+	user32                     = syscall.NewLazyDLL("user32.dll")
+	getWindowLongW             = user32.NewProc("GetWindowLongW")
+	setWindowLongW             = user32.NewProc("SetWindowLongW")
+	getLayeredWindowAttributes = user32.NewProc("GetLayeredWindowAttributes")
+	setLayeredWindowAttributes = user32.NewProc("SetLayeredWindowAttributes")
+	setWindowPos               = user32.NewProc("SetWindowPos")
+	getWindowRect              = user32.NewProc("GetWindowRect")
+	clientToScreen             = user32.NewProc("ClientToScreen")
+)
+
+type point struct {
+	x, y int32
+}
+
+type rect struct {
+	left, top, right, bottom int32
+}
+
+func keepNativeOnTop(handle uintptr) {
+	setWindowPos.Call(handle, uintptr(hwndTopMost), 0, 0, 0, 0, swpNoMove|swpNoSize|swpNoActivate)
+}
+
+// Positions are of the client area like GLFW's, the frame around it is placed accordingly
+func moveNativeWindow(handle uintptr, x, y int) {
+	var frame rect
+	getWindowRect.Call(handle, uintptr(unsafe.Pointer(&frame)))
+	clientX, clientY := nativeWindowPosition(handle)
+	frameX, frameY := x-(clientX-int(frame.left)), y-(clientY-int(frame.top))
+	setWindowPos.Call(handle, 0, uintptr(frameX), uintptr(frameY), 0, 0, swpNoSize|swpNoZOrder|swpNoActivate)
+}
+
+func nativeWindowPosition(handle uintptr) (x, y int) {
+	var origin point
+	clientToScreen.Call(handle, uintptr(unsafe.Pointer(&origin)))
+	return int(origin.x), int(origin.y)
+}
+
+// Transparent layered windows let clicks through. Layering is kept if something else set an
+// opacity with it, the same way GLFW 3.4 does for mouse passthrough.
+func setNativeClickThrough(handle uintptr, enabled bool) {
+	result, _, _ := getWindowLongW.Call(handle, uintptr(gwlExStyle))
+	style := uint32(result)
+
+	var key, alpha, flags uint32
+	if style&wsExLayered != 0 {
+		getLayeredWindowAttributes.Call(handle, uintptr(unsafe.Pointer(&key)), uintptr(unsafe.Pointer(&alpha)),
+			uintptr(unsafe.Pointer(&flags)))
+	}
+	if enabled {
+		style |= wsExTransparent | wsExLayered
+	} else {
+		style &^= wsExTransparent
+		if style&wsExLayered != 0 && flags&lwaAlpha == 0 {
+			style &^= wsExLayered
+		}
+	}
+	setWindowLongW.Call(handle, uintptr(gwlExStyle), uintptr(style))
+	if enabled {
+		setLayeredWindowAttributes.Call(handle, uintptr(key), uintptr(alpha), uintptr(flags))
+	}
+}
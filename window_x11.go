@@ -0,0 +1,81 @@
+//go:build (linux || freebsd || netbsd || openbsd) && !wayland
+
+package main
+
+/*
+#cgo LDFLAGS: -lX11 -lXext
+#include <X11/Xlib.h>
+#include <X11/extensions/shape.h>
+
+// An empty input shape lets every click through, resetting it takes them again
+static void setClickThrough(Display *display, Window window, int enabled) {
+	if (enabled) {
+		XShapeCombineRectangles(display, window, ShapeInput, 0, 0, NULL, 0, ShapeSet, YXBanded);
+	} else {
+		XShapeCombineMask(display, window, ShapeInput, 0, 0, None, ShapeSet);
+	}
+	XFlush(display);
+}
+
+// Asks the window manager to keep the mapped window above the others, like GLFW's floating hint
+static void keepAbove(Display *display, Window window) {
+	XEvent event = {0};
+	event.xclient.type = ClientMessage;
+	event.xclient.window = window;
+	event.xclient.format = 32;
+	event.xclient.message_type = XInternAtom(display, "_NET_WM_STATE", False);
+	event.xclient.data.l[0] = 1; // _NET_WM_STATE_ADD
+	event.xclient.data.l[1] = XInternAtom(display, "_NET_WM_STATE_ABOVE", False);
+	event.xclient.data.l[3] = 1; // sent by a normal application
+	XSendEvent(display, DefaultRootWindow(display), False,
+		SubstructureNotifyMask | SubstructureRedirectMask, &event);
+	XFlush(display);
+}
+
+// GLFW gives its windows static gravity, so they're moved by where their content goes
+static void moveWindow(Display *display, Window window, int x, int y) {
+	XMoveWindow(display, window, x, y);
+	XFlush(display);
+}
+
+static void windowPosition(Display *display, Window window, int *x, int *y) {
+	Window child;
+	XTranslateCoordinates(display, window, DefaultRootWindow(display), 0, 0, x, y, &child);
+}
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+const clickThroughSupported = true
+
+// The connection fyne's windows were made on
+func x11Display() *C.Display {
+	return (*C.Display)(unsafe.Pointer(glfw.GetX11Display()))
+}
+
+func keepNativeOnTop(handle uintptr) {
+	C.keepAbove(x11Display(), C.Window(handle))
+}
+
+func moveNativeWindow(handle uintptr, x, y int) {
+	C.moveWindow(x11Display(), C.Window(handle), C.int(x), C.int(y))
+}
+
+func nativeWindowPosition(handle uintptr) (x, y int) {
+	var cx, cy C.int
+	C.windowPosition(x11Display(), C.Window(handle), &cx, &cy)
+	return int(cx), int(cy)
+}
+
+func setNativeClickThrough(handle uintptr, enabled bool) {
+	flag := 0
+	if enabled {
+		flag = 1
+	}
+	C.setClickThrough(x11Display(), C.Window(handle), C.int(flag))
+}